@@ -2,55 +2,106 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"github.com/thornhall/blog/internal/backup"
+	"github.com/thornhall/blog/internal/config"
 	"github.com/thornhall/blog/internal/db"
 	"github.com/thornhall/blog/internal/handler"
 	"github.com/thornhall/blog/internal/logging"
 	"github.com/thornhall/blog/internal/repo"
 	"github.com/thornhall/blog/internal/router"
 	"github.com/thornhall/blog/internal/tasks"
+	"github.com/thornhall/blog/internal/visitor"
 	"golang.org/x/crypto/acme/autocert"
 )
 
-func NewServer(ctx context.Context, publicDir, domain string) *http.Server {
+func NewServer(ctx context.Context, cfg config.Config) (*http.Server, error) {
 	logger := logging.New(os.Stdout)
-	database := db.New()
+	database, err := db.New(db.Config{
+		Path:            cfg.Database.Path,
+		BusyTimeout:     cfg.Database.BusyTimeout,
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+		Pragmas:         cfg.Database.Pragmas,
+	})
+	if err != nil {
+		return nil, err
+	}
 	rep := repo.New(database)
-	hnd := handler.New(rep, logger, publicDir)
-	mux := router.New(hnd, logger, publicDir)
 
-	if _, err := database.Exec("PRAGMA journal_mode=WAL;"); err != nil {
-		logger.Error("failed to enable WAL mode", "error", err)
+	var visitors *visitor.Hasher
+	if cfg.Visitors.Secret != "" {
+		visitors = visitor.New([]byte(cfg.Visitors.Secret), cfg.Visitors.RotateDaily)
+
+		// Hashing stored addresses is permanent, so it only happens with
+		// the real secret, never the throwaway one below.
+		if n, err := rep.HashLegacyVisitors(ctx, visitors.ID); err != nil {
+			return nil, fmt.Errorf("could not hash stored visitor addresses: %w", err)
+		} else if n > 0 {
+			logger.Info("hashed stored visitor addresses", "rows", n)
+		}
+	} else {
+		// IDs change on every restart, so views and likes can be counted
+		// again until a secret is set.
+		logger.Warn("BLOG_VISITOR_SECRET is not set, using a throwaway key and leaving stored addresses unhashed")
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		visitors = visitor.New(secret, cfg.Visitors.RotateDaily)
+	}
+
+	if cfg.Visitors.Retention > 0 {
+		tasks.NewPurgeService(rep, cfg.Visitors.Retention, cfg.Visitors.PurgeInterval).Start(ctx)
+	}
+
+	feedPath := filepath.Join(cfg.Paths.Public, "feed.json")
+	if n, err := tasks.SyncSearchIndex(ctx, rep, feedPath); err != nil {
+		logger.Error("failed to sync search index", "error", err, "feed", feedPath)
+	} else {
+		logger.Info("synced search index", "posts", n)
+	}
+
+	proxies, err := handler.ParseTrustedProxies(cfg.Server.TrustedProxies, cfg.Server.TrustCloudflare)
+	if err != nil {
+		return nil, err
 	}
 
-	if domain != "" {
+	hnd := handler.New(rep, logger, cfg.Paths.Public, cfg.Database.Path, visitors, proxies)
+	mux := router.New(hnd, logger, cfg.Paths.Public, cfg.Paths.Assets)
+
+	if domain := cfg.Server.Domain; domain != "" {
 		logger.Info("configuring production server (HTTPS)", "domain", domain)
 
 		certManager := autocert.Manager{
 			Prompt:     autocert.AcceptTOS,
 			HostPolicy: autocert.HostWhitelist(domain, "www."+domain),
-			Cache:      autocert.DirCache("certs"),
+			Cache:      autocert.DirCache(cfg.Server.CertDir),
 		}
 
 		go func() {
-			logger.Info("starting http redirect server", "addr", ":80")
-			if err := http.ListenAndServe(":80", certManager.HTTPHandler(nil)); err != nil {
+			logger.Info("starting http redirect server", "addr", cfg.Server.RedirectAddr)
+			if err := http.ListenAndServe(cfg.Server.RedirectAddr, certManager.HTTPHandler(nil)); err != nil {
 				logger.Error("redirect server failed", "error", err)
 			}
 		}()
 
 		return &http.Server{
-			Addr:    ":443",
+			Addr:    cfg.Server.HTTPSAddr,
 			Handler: mux,
 			TLSConfig: &tls.Config{
 				GetCertificate: certManager.GetCertificate,
@@ -63,12 +114,12 @@ func NewServer(ctx context.Context, publicDir, domain string) *http.Server {
 			BaseContext: func(l net.Listener) context.Context {
 				return ctx
 			},
-		}
+		}, nil
 	}
 
-	logger.Info("configuring development server (HTTP)", "addr", ":8080")
+	logger.Info("configuring development server (HTTP)", "addr", cfg.Server.HTTPAddr)
 	return &http.Server{
-		Addr:              ":8080",
+		Addr:              cfg.Server.HTTPAddr,
 		Handler:           mux,
 		ReadTimeout:       10 * time.Second,
 		IdleTimeout:       120 * time.Second,
@@ -77,19 +128,32 @@ func NewServer(ctx context.Context, publicDir, domain string) *http.Server {
 		BaseContext: func(l net.Listener) context.Context {
 			return ctx
 		},
-	}
+	}, nil
 }
 
 func main() {
+	configPath := flag.String("config", "blog.yaml", "path to the site config file")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := cfg.ValidateServer(); err != nil {
+		log.Fatalf("invalid config: %v", err)
+	}
+
 	engineCtx, cancelEngine := context.WithCancel(context.Background())
 	defer cancelEngine()
 
-	domain := os.Getenv("DOMAIN")
-	srv := NewServer(engineCtx, "./public", domain)
+	srv, err := NewServer(engineCtx, cfg)
+	if err != nil {
+		log.Fatalf("unable to create server: %v", err)
+	}
 
 	go func() {
 		var err error
-		if domain != "" {
+		if cfg.Server.Domain != "" {
 			err = srv.ListenAndServeTLS("", "")
 		} else {
 			err = srv.ListenAndServe()
@@ -103,12 +167,22 @@ func main() {
 	backupCtx, cancelBackup := context.WithCancel(context.Background())
 	defer cancelBackup()
 
-	backupClient, err := backup.NewSpaceClient()
-	if err != nil {
-		log.Printf("error getting S3 client: %v", err)
+	if cfg.BackupsEnabled() {
+		backupClient, err := backup.NewSpaceClient(backup.SpaceConfig{
+			Key:      cfg.Backup.Key,
+			Secret:   cfg.Backup.Secret,
+			Endpoint: cfg.Backup.Endpoint,
+			Region:   cfg.Backup.Region,
+			Bucket:   cfg.Backup.Bucket,
+		})
+		if err != nil {
+			log.Printf("error getting S3 client: %v", err)
+		} else {
+			backupWorker := tasks.NewBackupService(backupClient, cfg.Database.Path, cfg.Backup.ObjectKey, cfg.Backup.Interval)
+			backupWorker.Start(backupCtx)
+		}
 	} else {
-		backupWorker := tasks.NewBackupService(backupClient, "blog.db", time.Hour)
-		backupWorker.Start(backupCtx)
+		log.Printf("backups disabled (env %q)", cfg.Server.Env)
 	}
 
 	shutDownChan := make(chan os.Signal, 1)
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -17,20 +16,20 @@ type SpaceClient struct {
 	Bucket string
 }
 
-func NewSpaceClient() (*SpaceClient, error) {
-	if os.Getenv("ENV") != "prod" {
-		return nil, fmt.Errorf("environment is not prod - not configuring backups")
-	}
-	key := os.Getenv("SPACES_KEY")
-	secret := os.Getenv("SPACES_SECRET")
-	endpoint := os.Getenv("SPACES_ENDPOINT")
-	region := os.Getenv("SPACES_REGION")
-	bucket := os.Getenv("SPACES_BUCKET")
+// SpaceConfig holds the DigitalOcean Spaces connection settings.
+type SpaceConfig struct {
+	Key      string
+	Secret   string
+	Endpoint string
+	Region   string
+	Bucket   string
+}
 
-	creds := credentials.NewStaticCredentialsProvider(key, secret, "")
+func NewSpaceClient(sc SpaceConfig) (*SpaceClient, error) {
+	creds := credentials.NewStaticCredentialsProvider(sc.Key, sc.Secret, "")
 
 	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithRegion(region),
+		config.WithRegion(sc.Region),
 		config.WithCredentialsProvider(creds),
 	)
 	if err != nil {
@@ -38,12 +37,12 @@ func NewSpaceClient() (*SpaceClient, error) {
 	}
 
 	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
-		o.BaseEndpoint = aws.String(endpoint)
+		o.BaseEndpoint = aws.String(sc.Endpoint)
 	})
 
 	return &SpaceClient{
 		Client: client,
-		Bucket: bucket,
+		Bucket: sc.Bucket,
 	}, nil
 }
 
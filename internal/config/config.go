@@ -0,0 +1,347 @@
+// Package config loads the blog's settings, shared by the builder and the
+// server, from a YAML file with environment variable overrides.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+type Config struct {
+	Site     Site     `yaml:"site"`
+	Paths    Paths    `yaml:"paths"`
+	Build    Build    `yaml:"build"`
+	Server   Server   `yaml:"server"`
+	Database Database `yaml:"database"`
+	Visitors Visitors `yaml:"visitors"`
+	Backup   Backup   `yaml:"backup"`
+}
+
+type Site struct {
+	Title   string `yaml:"title"`
+	Tagline string `yaml:"tagline"`
+	Author  string `yaml:"author"`
+	BaseURL string `yaml:"base_url"`
+
+	// Image is the default social preview image, as a site path or an
+	// absolute URL. Posts can override it with `image` front matter.
+	Image string `yaml:"image"`
+}
+
+type Paths struct {
+	Content   string `yaml:"content"`
+	Templates string `yaml:"templates"`
+	Assets    string `yaml:"assets"`
+	Public    string `yaml:"public"`
+	Manifest  string `yaml:"manifest"`
+}
+
+type Build struct {
+	PageSize int `yaml:"page_size"`
+}
+
+type Server struct {
+	// Env is "prod" on the VPS. Any other value means dev, since ENV is
+	// also set by some shells. Backups only run in prod.
+	Env string `yaml:"env"`
+
+	// Domain enables HTTPS with autocert when set. HTTPAddr is used
+	// otherwise.
+	Domain       string `yaml:"domain"`
+	HTTPAddr     string `yaml:"http_addr"`
+	HTTPSAddr    string `yaml:"https_addr"`
+	RedirectAddr string `yaml:"redirect_addr"`
+	CertDir      string `yaml:"cert_dir"`
+
+	// TrustedProxies are the CIDRs (or addresses) allowed to set
+	// X-Forwarded-For and X-Real-IP. TrustCloudflare adds Cloudflare's
+	// published ranges. With neither, the headers are ignored.
+	TrustedProxies  []string `yaml:"trusted_proxies"`
+	TrustCloudflare bool     `yaml:"trust_cloudflare"`
+}
+
+type Database struct {
+	// Path is the SQLite file, shared by the server, the stats stream and
+	// the backup worker.
+	Path        string        `yaml:"path"`
+	BusyTimeout time.Duration `yaml:"busy_timeout"`
+
+	// Pool limits. Zero leaves the database/sql default.
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+
+	// Pragmas are set on every connection, e.g. journal_mode: WAL. Setting
+	// any in the config file replaces the defaults.
+	Pragmas map[string]string `yaml:"pragmas"`
+}
+
+// Visitors configures how client addresses are hashed into the visitor IDs
+// that views and likes are deduplicated by.
+type Visitors struct {
+	// Secret keys the hash and should only be supplied through the
+	// environment. Stored addresses are hashed with it once, which can't be
+	// undone, so it must never change afterwards: a new secret gives every
+	// visitor a new ID and counts their views and likes again. Without one
+	// the server uses a throwaway key and leaves stored addresses alone.
+	Secret string `yaml:"secret"`
+
+	// RotateDaily gives every visitor a new ID each UTC day, so a view or
+	// like can be counted again the next day.
+	RotateDaily bool `yaml:"rotate_daily"`
+
+	// Retention is how long a visitor's view and like rows are kept for
+	// deduplication. Zero keeps them forever. Older rows are deleted every
+	// PurgeInterval; the counts in post_stats are never touched.
+	Retention     time.Duration `yaml:"retention"`
+	PurgeInterval time.Duration `yaml:"purge_interval"`
+}
+
+type Backup struct {
+	Enabled   bool          `yaml:"enabled"`
+	Interval  time.Duration `yaml:"interval"`
+	ObjectKey string        `yaml:"object_key"`
+	Endpoint  string        `yaml:"endpoint"`
+	Region    string        `yaml:"region"`
+	Bucket    string        `yaml:"bucket"`
+
+	// Credentials are normally only supplied through the environment.
+	Key    string `yaml:"key"`
+	Secret string `yaml:"secret"`
+}
+
+// Default returns the settings used when neither the config file nor the
+// environment say otherwise.
+func Default() Config {
+	return Config{
+		Site: Site{
+			Title:   "blog.info()",
+			Tagline: "Backend Engineer obsessed with simplicity and scalability.",
+			Author:  "Thorn Hall",
+			BaseURL: "https://thorn.sh",
+			Image:   "/assets/social-preview.png",
+		},
+		Paths: Paths{
+			Content:   "content",
+			Templates: "templates",
+			Assets:    "assets",
+			Public:    "public",
+			Manifest:  ".build-manifest.json",
+		},
+		Build: Build{
+			PageSize: 10,
+		},
+		Server: Server{
+			Env:          "dev",
+			HTTPAddr:     ":8080",
+			HTTPSAddr:    ":443",
+			RedirectAddr: ":80",
+			CertDir:      "certs",
+		},
+		Database: Database{
+			Path:        "blog.db",
+			BusyTimeout: 5 * time.Second,
+			Pragmas: map[string]string{
+				"journal_mode": "WAL",
+			},
+		},
+		Visitors: Visitors{
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: 24 * time.Hour,
+		},
+		Backup: Backup{
+			Enabled:   true,
+			Interval:  time.Hour,
+			ObjectKey: "backups/blog.db",
+		},
+	}
+}
+
+// Load reads the YAML file at path over the defaults, applies environment
+// overrides and validates the result. An empty path skips the file.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		// Strict decoding rejects keys already in a map, so maps from
+		// the file replace the defaults rather than merge with them.
+		cfg.Database.Pragmas = nil
+		if err := yaml.UnmarshalStrict(raw, &cfg); err != nil {
+			return cfg, fmt.Errorf("%s: %w", path, err)
+		}
+		if cfg.Database.Pragmas == nil {
+			cfg.Database.Pragmas = Default().Database.Pragmas
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid config: %w", err)
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides settings from the environment. DOMAIN, ENV and the
+// SPACES_* variables keep the names the server has always read.
+func (c *Config) applyEnv() error {
+	overrides := map[string]*string{
+		"DOMAIN":              &c.Server.Domain,
+		"ENV":                 &c.Server.Env,
+		"SPACES_KEY":          &c.Backup.Key,
+		"SPACES_SECRET":       &c.Backup.Secret,
+		"SPACES_ENDPOINT":     &c.Backup.Endpoint,
+		"SPACES_REGION":       &c.Backup.Region,
+		"SPACES_BUCKET":       &c.Backup.Bucket,
+		"BLOG_BASE_URL":       &c.Site.BaseURL,
+		"BLOG_PUBLIC_DIR":     &c.Paths.Public,
+		"BLOG_DB_PATH":        &c.Database.Path,
+		"BLOG_HTTP_ADDR":      &c.Server.HTTPAddr,
+		"BLOG_VISITOR_SECRET": &c.Visitors.Secret,
+	}
+	for name, field := range overrides {
+		if v, ok := os.LookupEnv(name); ok {
+			*field = v
+		}
+	}
+
+	if v, ok := os.LookupEnv("BLOG_BACKUP_ENABLED"); ok {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("BLOG_BACKUP_ENABLED: %w", err)
+		}
+		c.Backup.Enabled = enabled
+	}
+	if v, ok := os.LookupEnv("BLOG_BACKUP_INTERVAL"); ok {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("BLOG_BACKUP_INTERVAL: %w", err)
+		}
+		c.Backup.Interval = interval
+	}
+	return nil
+}
+
+// IsProd reports whether the server is running in production.
+func (c Config) IsProd() bool {
+	return c.Server.Env == "prod"
+}
+
+// BackupsEnabled reports whether the backup worker should run.
+func (c Config) BackupsEnabled() bool {
+	return c.IsProd() && c.Backup.Enabled
+}
+
+// Validate reports every problem with the settings the builder and server
+// share at once. Load calls it.
+func (c Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Site.Title == "" {
+		fail("site.title is required")
+	}
+	if u, err := url.Parse(c.Site.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fail("site.base_url %q must be an absolute http(s) URL", c.Site.BaseURL)
+	}
+
+	if c.Paths.Content == "" || c.Paths.Templates == "" || c.Paths.Public == "" {
+		fail("paths.content, paths.templates and paths.public are required")
+	}
+
+	if c.Build.PageSize < 1 {
+		fail("build.page_size must be at least 1, got %d", c.Build.PageSize)
+	}
+
+	return errors.Join(errs...)
+}
+
+// minVisitorSecret is the shortest secret accepted for visitor IDs.
+const minVisitorSecret = 32
+
+// ValidateServer reports every problem with the server, database and backup
+// settings at once. The builder never needs them, so Load leaves them to the
+// server.
+func (c Config) ValidateServer() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	for _, addr := range []struct{ name, value string }{
+		{"server.http_addr", c.Server.HTTPAddr},
+		{"server.https_addr", c.Server.HTTPSAddr},
+		{"server.redirect_addr", c.Server.RedirectAddr},
+	} {
+		if _, _, err := net.SplitHostPort(addr.value); err != nil {
+			fail("%s %q is not a valid listen address", addr.name, addr.value)
+		}
+	}
+	if c.Server.Domain != "" && c.Server.CertDir == "" {
+		fail("server.cert_dir is required when server.domain is set")
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, err := netip.ParsePrefix(proxy); err != nil {
+			if _, err := netip.ParseAddr(proxy); err != nil {
+				fail("server.trusted_proxies: %q is not a CIDR or IP address", proxy)
+			}
+		}
+	}
+
+	if c.Database.Path == "" {
+		fail("database.path is required")
+	}
+	if c.Database.BusyTimeout < 0 {
+		fail("database.busy_timeout must not be negative")
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 || c.Database.ConnMaxLifetime < 0 {
+		fail("database pool limits must not be negative")
+	}
+
+	if n := len(c.Visitors.Secret); n > 0 && n < minVisitorSecret {
+		fail("visitors.secret (BLOG_VISITOR_SECRET) must be at least %d bytes", minVisitorSecret)
+	}
+	if c.Visitors.Retention < 0 {
+		fail("visitors.retention must not be negative")
+	}
+	if c.Visitors.Retention > 0 && c.Visitors.PurgeInterval <= 0 {
+		fail("visitors.purge_interval must be positive when visitors.retention is set")
+	}
+
+	if c.BackupsEnabled() {
+		if c.Backup.Interval <= 0 {
+			fail("backup.interval must be positive")
+		}
+		if c.Backup.ObjectKey == "" {
+			fail("backup.object_key is required")
+		}
+		for _, field := range []struct{ name, value string }{
+			{"backup.endpoint (SPACES_ENDPOINT)", c.Backup.Endpoint},
+			{"backup.region (SPACES_REGION)", c.Backup.Region},
+			{"backup.bucket (SPACES_BUCKET)", c.Backup.Bucket},
+			{"backup.key (SPACES_KEY)", c.Backup.Key},
+			{"backup.secret (SPACES_SECRET)", c.Backup.Secret},
+		} {
+			if field.value == "" {
+				fail("%s is required when backups are enabled in prod", field.name)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
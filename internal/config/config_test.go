@@ -0,0 +1,309 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thornhall/blog/internal/config"
+)
+
+var envVars = []string{
+	"DOMAIN", "ENV", "SPACES_KEY", "SPACES_SECRET", "SPACES_ENDPOINT", "SPACES_REGION", "SPACES_BUCKET",
+	"BLOG_BASE_URL", "BLOG_PUBLIC_DIR", "BLOG_DB_PATH", "BLOG_HTTP_ADDR", "BLOG_VISITOR_SECRET",
+	"BLOG_BACKUP_ENABLED", "BLOG_BACKUP_INTERVAL",
+}
+
+// clearEnv unsets every variable Load reads for the duration of the test.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range envVars {
+		if v, ok := os.LookupEnv(name); ok {
+			require.NoError(t, os.Unsetenv(name))
+			t.Cleanup(func() { os.Setenv(name, v) })
+		}
+	}
+}
+
+func writeConfig(t *testing.T, yaml string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "blog.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(yaml), 0644))
+	return path
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(raw)
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		env     map[string]string
+		check   func(t *testing.T, cfg config.Config)
+		wantErr string
+	}{
+		{
+			name: "no file gives the defaults",
+			check: func(t *testing.T, cfg config.Config) {
+				assert.Equal(t, config.Default(), cfg)
+			},
+		},
+		{
+			name: "file values are merged over the defaults",
+			yaml: "site:\n  title: Other\nbuild:\n  page_size: 5\n",
+			check: func(t *testing.T, cfg config.Config) {
+				assert.Equal(t, "Other", cfg.Site.Title)
+				assert.Equal(t, 5, cfg.Build.PageSize)
+				assert.Equal(t, config.Default().Site.BaseURL, cfg.Site.BaseURL, "unset keys keep their default")
+				assert.Equal(t, config.Default().Database.Pragmas, cfg.Database.Pragmas)
+			},
+		},
+		{
+			name: "pragmas in the file replace the defaults",
+			yaml: "database:\n  pragmas:\n    journal_mode: WAL\n    synchronous: NORMAL\n",
+			check: func(t *testing.T, cfg config.Config) {
+				assert.Equal(t, map[string]string{"journal_mode": "WAL", "synchronous": "NORMAL"}, cfg.Database.Pragmas)
+			},
+		},
+		{
+			name: "the repo's blog.yaml loads",
+			yaml: readFile(t, "../../blog.yaml"),
+			check: func(t *testing.T, cfg config.Config) {
+				assert.Empty(t, cfg.Server.TrustedProxies)
+				cfg.Server.TrustedProxies = nil
+				assert.Equal(t, config.Default(), cfg, "blog.yaml documents the defaults")
+			},
+		},
+		{
+			name:    "unknown keys are rejected",
+			yaml:    "site:\n  titel: Typo\n",
+			wantErr: "field titel not found",
+		},
+		{
+			name: "environment overrides the file",
+			yaml: "server:\n  http_addr: \":9000\"\n",
+			env: map[string]string{
+				"BLOG_HTTP_ADDR":       ":9090",
+				"BLOG_DB_PATH":         "/var/lib/blog/blog.db",
+				"BLOG_BACKUP_ENABLED":  "false",
+				"BLOG_BACKUP_INTERVAL": "30m",
+				"SPACES_BUCKET":        "bucket",
+			},
+			check: func(t *testing.T, cfg config.Config) {
+				assert.Equal(t, ":9090", cfg.Server.HTTPAddr)
+				assert.Equal(t, "/var/lib/blog/blog.db", cfg.Database.Path)
+				assert.False(t, cfg.Backup.Enabled)
+				assert.Equal(t, 30*time.Minute, cfg.Backup.Interval)
+				assert.Equal(t, "bucket", cfg.Backup.Bucket)
+			},
+		},
+		{
+			name:    "bad BLOG_BACKUP_ENABLED",
+			env:     map[string]string{"BLOG_BACKUP_ENABLED": "sometimes"},
+			wantErr: "BLOG_BACKUP_ENABLED",
+		},
+		{
+			name:    "bad BLOG_BACKUP_INTERVAL",
+			env:     map[string]string{"BLOG_BACKUP_INTERVAL": "hourly"},
+			wantErr: "BLOG_BACKUP_INTERVAL",
+		},
+		{
+			name:    "invalid settings fail validation",
+			yaml:    "build:\n  page_size: 0\n",
+			wantErr: "build.page_size must be at least 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			path := ""
+			if tt.yaml != "" {
+				path = writeConfig(t, tt.yaml)
+			}
+
+			cfg, err := config.Load(path)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	clearEnv(t)
+	_, err := config.Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *config.Config)
+		want   []string
+	}{
+		{name: "defaults are valid", modify: func(cfg *config.Config) {}},
+		{
+			name: "every problem is reported",
+			modify: func(cfg *config.Config) {
+				cfg.Site.Title = ""
+				cfg.Site.BaseURL = "thorn.sh"
+				cfg.Paths.Public = ""
+				cfg.Build.PageSize = -1
+			},
+			want: []string{
+				"site.title is required",
+				`site.base_url "thorn.sh" must be an absolute http(s) URL`,
+				"paths.content, paths.templates and paths.public are required",
+				"build.page_size must be at least 1, got -1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if len(tt.want) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, want := range tt.want {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
+
+func TestValidateServer(t *testing.T) {
+	prod := func(cfg *config.Config) {
+		cfg.Server.Env = "prod"
+		cfg.Visitors.Secret = "0123456789abcdef0123456789abcdef"
+		cfg.Backup.Endpoint = "https://nyc3.digitaloceanspaces.com"
+		cfg.Backup.Region = "nyc3"
+		cfg.Backup.Bucket = "bucket"
+		cfg.Backup.Key = "key"
+		cfg.Backup.Secret = "secret"
+	}
+
+	tests := []struct {
+		name   string
+		modify func(cfg *config.Config)
+		want   []string
+	}{
+		{name: "dev defaults are valid", modify: func(cfg *config.Config) {}},
+		{name: "complete prod config is valid", modify: prod},
+		{
+			name: "any env other than prod is dev",
+			modify: func(cfg *config.Config) {
+				cfg.Server.Env = "/root/.ashrc"
+			},
+		},
+		{
+			name: "prod with backups disabled needs no credentials",
+			modify: func(cfg *config.Config) {
+				cfg.Server.Env = "prod"
+				cfg.Visitors.Secret = "0123456789abcdef0123456789abcdef"
+				cfg.Backup.Enabled = false
+			},
+		},
+		{
+			name: "server settings",
+			modify: func(cfg *config.Config) {
+				cfg.Server.HTTPAddr = "8080"
+				cfg.Server.Domain = "thorn.sh"
+				cfg.Server.CertDir = ""
+				cfg.Server.TrustedProxies = []string{"10.0.0.0/8", "192.0.2.1", "proxy.internal"}
+			},
+			want: []string{
+				`server.http_addr "8080" is not a valid listen address`,
+				"server.cert_dir is required when server.domain is set",
+				`server.trusted_proxies: "proxy.internal" is not a CIDR or IP address`,
+			},
+		},
+		{
+			name: "database settings",
+			modify: func(cfg *config.Config) {
+				cfg.Database.Path = ""
+				cfg.Database.BusyTimeout = -time.Second
+				cfg.Database.MaxOpenConns = -1
+			},
+			want: []string{
+				"database.path is required",
+				"database.busy_timeout must not be negative",
+				"database pool limits must not be negative",
+			},
+		},
+		{
+			name: "visitor settings",
+			modify: func(cfg *config.Config) {
+				cfg.Visitors.Retention = time.Hour
+				cfg.Visitors.PurgeInterval = 0
+			},
+			want: []string{"visitors.purge_interval must be positive when visitors.retention is set"},
+		},
+		{
+			name: "prod backups need credentials",
+			modify: func(cfg *config.Config) {
+				prod(cfg)
+				cfg.Backup.Bucket = ""
+				cfg.Backup.Secret = ""
+				cfg.Backup.Interval = 0
+			},
+			want: []string{
+				"backup.interval must be positive",
+				"backup.bucket (SPACES_BUCKET) is required when backups are enabled in prod",
+				"backup.secret (SPACES_SECRET) is required when backups are enabled in prod",
+			},
+		},
+		{
+			name: "prod runs without a visitor secret",
+			modify: func(cfg *config.Config) {
+				prod(cfg)
+				cfg.Visitors.Secret = ""
+			},
+		},
+		{
+			name: "visitor secret must be long enough",
+			modify: func(cfg *config.Config) {
+				cfg.Visitors.Secret = "short"
+			},
+			want: []string{"visitors.secret (BLOG_VISITOR_SECRET) must be at least 32 bytes"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			tt.modify(&cfg)
+
+			err := cfg.ValidateServer()
+			if len(tt.want) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, want := range tt.want {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
@@ -3,7 +3,11 @@ package db
 import (
 	"context"
 	"database/sql"
-	"log"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
@@ -13,49 +17,82 @@ type DB interface {
 	Exec(query string, args ...any) (sql.Result, error)
 }
 
-// Creates and returns a new DB, exiting if it fails to do so.
-func New() *sql.DB {
-	db, err := sql.Open("sqlite", "file:./blog.db?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
-	if err != nil {
-		log.Fatal(err)
-	}
-	initDB(db)
-	return db
-}
+// Config describes how to open the SQLite database.
+type Config struct {
+	// Path is the database file. The backup worker and the stats stream
+	// read the same file.
+	Path string
 
-func initDB(db *sql.DB) {
-	query := `CREATE TABLE IF NOT EXISTS post_stats (
-		slug TEXT PRIMARY KEY, 
-		views INTEGER DEFAULT 0, 
-		likes INTEGER DEFAULT 0
-	);`
+	// BusyTimeout is how long a connection waits on a locked database
+	// before failing.
+	BusyTimeout time.Duration
 
-	query2 := `CREATE TABLE IF NOT EXISTS ip_likes (
-		ip TEXT,
-		post_slug TEXT REFERENCES post_stats(slug),
+	// Pool limits. Zero leaves the database/sql default.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
 
-		PRIMARY KEY (ip, post_slug)
-	);`
+	// Pragmas are run on every new connection, e.g. journal_mode: WAL.
+	Pragmas map[string]string
+}
 
-	query3 := `CREATE TABLE IF NOT EXISTS ip_views (
-		ip TEXT,
-		post_slug TEXT REFERENCES post_stats(slug),
+// DSN returns the data source name for the modernc.org/sqlite driver.
+func (c Config) DSN() string {
+	pragmas := []string{fmt.Sprintf("busy_timeout(%d)", c.BusyTimeout.Milliseconds())}
 
-		PRIMARY KEY (ip, post_slug)
-	);`
+	names := make([]string, 0, len(c.Pragmas))
+	for name := range c.Pragmas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pragmas = append(pragmas, fmt.Sprintf("%s(%s)", name, c.Pragmas[name]))
+	}
 
-	_, err := db.Exec(query)
-	if err != nil {
-		log.Fatal(err)
+	query := url.Values{"_pragma": pragmas}
+	return "file:" + c.Path + "?" + query.Encode()
+}
+
+// Creates and returns a new DB for cfg, migrated to the latest schema.
+func New(cfg Config) (*sql.DB, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("database path is required")
+	}
+	for name, value := range cfg.Pragmas {
+		if !isPragmaName(name) || strings.ContainsAny(value, "()") {
+			return nil, fmt.Errorf("invalid pragma %s(%s)", name, value)
+		}
 	}
 
-	_, err = db.Exec(query2)
+	db, err := sql.Open("sqlite", cfg.DSN())
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 	}
 
-	_, err = db.Exec(query3)
-	if err != nil {
-		log.Fatal(err)
+	if err := Migrate(context.Background(), db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not migrate %s: %w", cfg.Path, err)
+	}
+	return db, nil
+}
+
+func isPragmaName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
 	}
+	return true
 }
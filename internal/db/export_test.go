@@ -0,0 +1,4 @@
+package db
+
+// MigrateFS applies the migrations in files instead of the embedded ones.
+var MigrateFS = migrate
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migrations are SQL files named NNNN_description.sql. Each is applied once,
+// in order of its number, and recorded in schema_migrations. A migration
+// that has shipped must never be edited; change the schema with a new file.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+type migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// loadMigrations returns the embedded migrations sorted by version.
+func loadMigrations(files fs.FS) ([]migration, error) {
+	paths, err := fs.Glob(files, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []migration
+	seen := make(map[int]string)
+	for _, p := range paths {
+		name := strings.TrimSuffix(path.Base(p), ".sql")
+		number, _, ok := strings.Cut(name, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: name must start with a positive version number and an underscore", p)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migration %s: version %d is already used by %s", p, version, other)
+		}
+		seen[version] = name
+
+		body, err := fs.ReadFile(files, p)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{Version: version, Name: name, SQL: string(body)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrate brings the schema of db up to date, applying each pending
+// migration in its own transaction. It refuses to touch a database that has
+// migrations this build does not know about, since that means it was last
+// used by a newer version of the server.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	return migrate(ctx, db, migrationFiles)
+}
+
+func migrate(ctx context.Context, db *sql.DB, files fs.FS) error {
+	migrations, err := loadMigrations(files)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`)
+	if err != nil {
+		return fmt.Errorf("could not create schema_migrations: %w", err)
+	}
+
+	current, err := SchemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	if latest := latestVersion(migrations); current > latest {
+		return fmt.Errorf("database schema is at version %d but this build only knows up to %d", current, latest)
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := apply(ctx, db, m); err != nil {
+			return err
+		}
+		log.Printf("Applied migration %s", m.Name)
+	}
+	return nil
+}
+
+// SchemaVersion returns the version of the last migration applied to db, or
+// 0 if there is none.
+func SchemaVersion(ctx context.Context, db DB) (int, error) {
+	var version int
+	err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("could not read schema version: %w", err)
+	}
+	return version, nil
+}
+
+func apply(ctx context.Context, db *sql.DB, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return fmt.Errorf("migration %s failed: %w", m.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name); err != nil {
+		return fmt.Errorf("could not record migration %s: %w", m.Name, err)
+	}
+	return tx.Commit()
+}
+
+func latestVersion(migrations []migration) int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
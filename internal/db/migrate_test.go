@@ -0,0 +1,128 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thornhall/blog/internal/db"
+	_ "modernc.org/sqlite"
+)
+
+// openMemory returns an empty in-memory database. A single connection keeps
+// every query on the same database.
+func openMemory(t *testing.T) *sql.DB {
+	t.Helper()
+	database, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	database.SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func tableExists(t *testing.T, database *sql.DB, name string) bool {
+	t.Helper()
+	var n int
+	err := database.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = ?", name).Scan(&n)
+	require.NoError(t, err)
+	return n > 0
+}
+
+func TestMigrate(t *testing.T) {
+	database := openMemory(t)
+
+	require.NoError(t, db.Migrate(t.Context(), database))
+	version, err := db.SchemaVersion(t.Context(), database)
+	require.NoError(t, err)
+	assert.Equal(t, 4, version)
+	for _, table := range []string{"post_stats", "ip_views", "ip_likes", "posts_fts"} {
+		assert.True(t, tableExists(t, database, table), table)
+	}
+
+	require.NoError(t, db.Migrate(t.Context(), database), "migrating twice is a no-op")
+	version, err = db.SchemaVersion(t.Context(), database)
+	require.NoError(t, err)
+	assert.Equal(t, 4, version)
+}
+
+func TestMigrateDatabaseFromBeforeMigrations(t *testing.T) {
+	database := openMemory(t)
+
+	// The schema the server created before it had migrations.
+	for _, query := range []string{
+		`CREATE TABLE post_stats (slug TEXT PRIMARY KEY, views INTEGER DEFAULT 0, likes INTEGER DEFAULT 0);`,
+		`CREATE TABLE ip_likes (ip TEXT, post_slug TEXT REFERENCES post_stats(slug), PRIMARY KEY (ip, post_slug));`,
+		`CREATE TABLE ip_views (ip TEXT, post_slug TEXT REFERENCES post_stats(slug), PRIMARY KEY (ip, post_slug));`,
+		`INSERT INTO post_stats (slug, views, likes) VALUES ('go-sqlite', 12, 3);`,
+		`INSERT INTO ip_views (ip, post_slug) VALUES ('203.0.113.7', 'go-sqlite');`,
+		`INSERT INTO ip_likes (ip, post_slug) VALUES ('203.0.113.7', 'go-sqlite');`,
+	} {
+		_, err := database.Exec(query)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, db.Migrate(t.Context(), database))
+
+	var views, likes int
+	require.NoError(t, database.QueryRow("SELECT views, likes FROM post_stats WHERE slug = 'go-sqlite'").Scan(&views, &likes))
+	assert.Equal(t, 12, views)
+	assert.Equal(t, 3, likes)
+
+	for _, table := range []string{"ip_views", "ip_likes"} {
+		var visitor string
+		var createdAt sql.NullString
+		err := database.QueryRow("SELECT visitor_id, created_at FROM "+table+" WHERE post_slug = 'go-sqlite'").Scan(&visitor, &createdAt)
+		require.NoError(t, err, table)
+		assert.Equal(t, "203.0.113.7", visitor, "rows are kept for the server to hash")
+		assert.True(t, createdAt.Valid, "existing rows get a created_at")
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	database := openMemory(t)
+	require.NoError(t, db.Migrate(t.Context(), database))
+
+	_, err := database.Exec("INSERT INTO schema_migrations (version, name) VALUES (99, '0099_from_the_future')")
+	require.NoError(t, err)
+
+	err = db.Migrate(t.Context(), database)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "database schema is at version 99")
+}
+
+func TestMigrateRollsBackFailedMigration(t *testing.T) {
+	database := openMemory(t)
+	files := fstest.MapFS{
+		"migrations/0001_first.sql": {Data: []byte("CREATE TABLE first (id INTEGER);")},
+		"migrations/0002_broken.sql": {Data: []byte(`
+			CREATE TABLE second (id INTEGER);
+			INSERT INTO missing VALUES (1);
+		`)},
+	}
+
+	err := db.MigrateFS(t.Context(), database, files)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "migration 0002_broken failed")
+
+	assert.True(t, tableExists(t, database, "first"), "earlier migrations stay applied")
+	assert.False(t, tableExists(t, database, "second"), "the failed migration is rolled back")
+	version, err := db.SchemaVersion(t.Context(), database)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+}
+
+func TestMigrateRejectsBadFileNames(t *testing.T) {
+	for name, files := range map[string]fstest.MapFS{
+		"no version": {"migrations/first.sql": {Data: []byte("SELECT 1;")}},
+		"duplicate version": {
+			"migrations/0001_a.sql": {Data: []byte("SELECT 1;")},
+			"migrations/01_b.sql":   {Data: []byte("SELECT 1;")},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Error(t, db.MigrateFS(t.Context(), openMemory(t), files))
+		})
+	}
+}
@@ -0,0 +1,21 @@
+-- Databases created before migrations existed already have these tables,
+-- so this first migration must not fail when they are present.
+CREATE TABLE IF NOT EXISTS post_stats (
+	slug TEXT PRIMARY KEY,
+	views INTEGER DEFAULT 0,
+	likes INTEGER DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS ip_likes (
+	ip TEXT,
+	post_slug TEXT REFERENCES post_stats(slug),
+
+	PRIMARY KEY (ip, post_slug)
+);
+
+CREATE TABLE IF NOT EXISTS ip_views (
+	ip TEXT,
+	post_slug TEXT REFERENCES post_stats(slug),
+
+	PRIMARY KEY (ip, post_slug)
+);
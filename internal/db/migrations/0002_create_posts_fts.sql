@@ -0,0 +1,10 @@
+-- posts_fts is the full-text index behind /api/search. The server refills it
+-- from the built site on every start.
+CREATE VIRTUAL TABLE IF NOT EXISTS posts_fts USING fts5(
+	slug UNINDEXED,
+	date UNINDEXED,
+	title,
+	excerpt,
+	body,
+	tokenize = 'porter unicode61'
+);
@@ -0,0 +1,6 @@
+-- Views and likes are deduplicated by a keyed hash of the visitor's address
+-- rather than the address itself. Rows written before this migration still
+-- hold raw addresses; the server hashes them at startup, since the key is
+-- not known here.
+ALTER TABLE ip_views RENAME COLUMN ip TO visitor_id;
+ALTER TABLE ip_likes RENAME COLUMN ip TO visitor_id;
@@ -0,0 +1,10 @@
+-- SQLite cannot add a column with a CURRENT_TIMESTAMP default, so inserts
+-- set created_at themselves. Existing rows start their retention window now.
+ALTER TABLE ip_views ADD COLUMN created_at TIMESTAMP;
+ALTER TABLE ip_likes ADD COLUMN created_at TIMESTAMP;
+
+UPDATE ip_views SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+UPDATE ip_likes SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS ip_views_created_at ON ip_views (created_at);
+CREATE INDEX IF NOT EXISTS ip_likes_created_at ON ip_likes (created_at);
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// cloudflareRanges are the networks Cloudflare proxies from, as published at
+// https://www.cloudflare.com/ips/.
+var cloudflareRanges = []string{
+	"173.245.48.0/20",
+	"103.21.244.0/22",
+	"103.22.200.0/22",
+	"103.31.4.0/22",
+	"141.101.64.0/18",
+	"108.162.192.0/18",
+	"190.93.240.0/20",
+	"188.114.96.0/20",
+	"197.234.240.0/22",
+	"198.41.128.0/17",
+	"162.158.0.0/15",
+	"104.16.0.0/13",
+	"104.24.0.0/14",
+	"172.64.0.0/13",
+	"131.0.72.0/22",
+	"2400:cb00::/32",
+	"2606:4700::/32",
+	"2803:f800::/32",
+	"2405:b500::/32",
+	"2405:8100::/32",
+	"2a06:98c0::/29",
+	"2c0f:f248::/32",
+}
+
+// TrustedProxies are the networks whose X-Forwarded-For and X-Real-IP
+// headers are believed. Anyone else could put any address in them.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies parses CIDRs, or bare addresses, adding Cloudflare's
+// ranges if cloudflare is set.
+func ParseTrustedProxies(cidrs []string, cloudflare bool) (TrustedProxies, error) {
+	if cloudflare {
+		cidrs = append(cloudflareRanges[:len(cloudflareRanges):len(cloudflareRanges)], cidrs...)
+	}
+
+	var proxies TrustedProxies
+	for _, cidr := range cidrs {
+		prefix, err := parseProxy(cidr)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, prefix)
+	}
+	return proxies, nil
+}
+
+// parseProxy parses a trusted proxy CIDR. A bare address is a network of
+// one.
+func parseProxy(cidr string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(cidr); err == nil {
+		return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+	}
+	return prefix.Masked(), nil
+}
+
+func (t TrustedProxies) trusts(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range t {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the normalized address of the client that sent r. The
+// forwarding headers are only read when the connection comes from a trusted
+// proxy. X-Forwarded-For is walked from the right, skipping the proxies the
+// request passed through, since only the entries they appended are reliable.
+func (t TrustedProxies) ClientIP(r *http.Request) string {
+	remote, ok := parseAddr(r.RemoteAddr)
+	if !ok {
+		return ""
+	}
+	if !t.trusts(remote) {
+		return NormalizeIP(remote.String())
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		client := remote
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, ok := parseAddr(strings.TrimSpace(hops[i]))
+			if !ok {
+				// Whoever wrote this entry is not a proxy we trust.
+				break
+			}
+			client = hop
+			if !t.trusts(hop) {
+				break
+			}
+		}
+		return NormalizeIP(client.String())
+	}
+
+	if xrip := r.Header.Get("X-Real-IP"); xrip != "" {
+		return NormalizeIP(xrip)
+	}
+
+	return NormalizeIP(remote.String())
+}
+
+// parseAddr parses an address with or without a port.
+func parseAddr(address string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
@@ -0,0 +1,171 @@
+package handler_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thornhall/blog/internal/handler"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := handler.ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1", "fd00::/8"}, false)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		proxies    handler.TrustedProxies
+		remoteAddr string
+		xff        []string
+		realIP     string
+		want       string
+	}{
+		{
+			name:       "direct connection",
+			proxies:    proxies,
+			remoteAddr: "203.0.113.7:51234",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "headers from an untrusted peer are ignored",
+			proxies:    proxies,
+			remoteAddr: "203.0.113.7:51234",
+			xff:        []string{"198.51.100.1"},
+			realIP:     "198.51.100.2",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "headers are ignored when nothing is trusted",
+			remoteAddr: "10.0.0.5:443",
+			xff:        []string{"198.51.100.1"},
+			want:       "10.0.0.5",
+		},
+		{
+			name:       "trusted proxy",
+			proxies:    proxies,
+			remoteAddr: "10.0.0.5:443",
+			xff:        []string{"203.0.113.7"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "forged entries left of the client are skipped",
+			proxies:    proxies,
+			remoteAddr: "10.0.0.5:443",
+			xff:        []string{"1.1.1.1, 203.0.113.7"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "chain of trusted proxies",
+			proxies:    proxies,
+			remoteAddr: "10.0.0.5:443",
+			xff:        []string{"1.1.1.1, 203.0.113.7, 192.0.2.1, 10.1.2.3"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "repeated headers are one list",
+			proxies:    proxies,
+			remoteAddr: "10.0.0.5:443",
+			xff:        []string{"1.1.1.1", "203.0.113.7, 10.1.2.3"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "every hop trusted",
+			proxies:    proxies,
+			remoteAddr: "10.0.0.5:443",
+			xff:        []string{"10.9.9.9, 192.0.2.1"},
+			want:       "10.9.9.9",
+		},
+		{
+			name:       "garbage stops the walk at the last trusted hop",
+			proxies:    proxies,
+			remoteAddr: "10.0.0.5:443",
+			xff:        []string{"not-an-ip, 192.0.2.1"},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "X-Real-IP from a trusted proxy",
+			proxies:    proxies,
+			remoteAddr: "10.0.0.5:443",
+			realIP:     "203.0.113.7",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "IPv6 client is masked to its /64",
+			proxies:    proxies,
+			remoteAddr: "[fd00::1]:443",
+			xff:        []string{"2001:db8:1:2:3:4:5:6"},
+			want:       "2001:db8:1:2::",
+		},
+		{
+			name:       "IPv4-mapped proxy address",
+			proxies:    proxies,
+			remoteAddr: "[::ffff:10.0.0.5]:443",
+			xff:        []string{"203.0.113.7"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "invalid remote address",
+			proxies:    proxies,
+			remoteAddr: "pipe",
+			xff:        []string{"203.0.113.7"},
+			want:       "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, xff := range tt.xff {
+				r.Header.Add("X-Forwarded-For", xff)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			assert.Equal(t, tt.want, tt.proxies.ClientIP(r))
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name       string
+		cidrs      []string
+		cloudflare bool
+		wantLen    int
+		wantErr    bool
+	}{
+		{name: "none", wantLen: 0},
+		{name: "cidrs and addresses", cidrs: []string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"}, wantLen: 3},
+		{name: "cloudflare", cloudflare: true, wantLen: 22},
+		{name: "cloudflare and more", cidrs: []string{"10.0.0.0/8"}, cloudflare: true, wantLen: 23},
+		{name: "invalid", cidrs: []string{"10.0.0.0/33"}, wantErr: true},
+		{name: "hostname", cidrs: []string{"proxy.internal"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxies, err := handler.ParseTrustedProxies(tt.cidrs, tt.cloudflare)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, proxies, tt.wantLen)
+		})
+	}
+}
+
+func TestClientIPCloudflare(t *testing.T) {
+	proxies, err := handler.ParseTrustedProxies(nil, true)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "162.158.1.1:443"
+	r.Header.Set("X-Forwarded-For", "1.1.1.1, 203.0.113.7")
+	assert.Equal(t, "203.0.113.7", proxies.ClientIP(r))
+
+	r.RemoteAddr = "203.0.113.9:443"
+	assert.Equal(t, "203.0.113.9", proxies.ClientIP(r), "only Cloudflare's own addresses are trusted")
+}
@@ -5,50 +5,42 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
 	"log/slog"
 	"net"
 	"net/http"
 	"os"
-	"regexp"
 	"runtime"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/thornhall/blog/internal/repo"
+	"github.com/thornhall/blog/internal/slug"
+	"github.com/thornhall/blog/internal/visitor"
 )
 
 type Handler struct {
-	repo *repo.Repo
-	log  *slog.Logger
-	fs   http.FileSystem
+	repo     *repo.Repo
+	log      *slog.Logger
+	fs       http.FileSystem
+	dbPath   string
+	visitors *visitor.Hasher
+	proxies  TrustedProxies
 }
 
-func New(repo *repo.Repo, log *slog.Logger, publicDir string) *Handler {
+func New(repo *repo.Repo, log *slog.Logger, publicDir, dbPath string, visitors *visitor.Hasher, proxies TrustedProxies) *Handler {
 	return &Handler{
-		repo: repo,
-		log:  log,
-		fs:   http.Dir(publicDir),
+		repo:     repo,
+		log:      log,
+		fs:       http.Dir(publicDir),
+		dbPath:   dbPath,
+		visitors: visitors,
+		proxies:  proxies,
 	}
 }
 
-// GetClientIP extracts the IP and immediately normalizes it.
-func GetClientIP(r *http.Request) string {
-	// 1. Check Cloudflare/Proxy Header
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// XFF can be "client, proxy1, proxy2". We want the first one.
-		ips := strings.Split(xff, ",")
-		return NormalizeIP(strings.TrimSpace(ips[0]))
-	}
-
-	// 2. Check Nginx/Standard Proxy Header
-	if xrip := r.Header.Get("X-Real-IP"); xrip != "" {
-		return NormalizeIP(xrip)
-	}
-
-	// 3. Fallback to Direct Connection
-	return NormalizeIP(r.RemoteAddr)
-}
-
 func NormalizeIP(address string) string {
 	host, _, err := net.SplitHostPort(address)
 	if err == nil {
@@ -72,10 +64,26 @@ func NormalizeIP(address string) string {
 	return maskedIP.String()
 }
 
-var slugRegex = regexp.MustCompile(`^[a-z0-9-]+$`)
+// maxSearchQueryLen is the longest search query accepted, in characters.
+const maxSearchQueryLen = 100
 
-func isValidSlug(slug string) bool {
-	return len(slug) > 0 && len(slug) < 100 && slugRegex.MatchString(slug)
+// isValidSearchQuery reports whether q is acceptable as a search query: at
+// most maxSearchQueryLen characters, no control characters, and at least one
+// letter or digit to search for.
+func isValidSearchQuery(q string) bool {
+	if utf8.RuneCountInString(q) > maxSearchQueryLen || !utf8.ValidString(q) {
+		return false
+	}
+	hasWord := false
+	for _, r := range q {
+		if unicode.IsControl(r) {
+			return false
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			hasWord = true
+		}
+	}
+	return hasWord
 }
 
 type ErrorResponse struct {
@@ -90,16 +98,16 @@ func HttpErrorResponse(w http.ResponseWriter, message string, statusCode int) {
 }
 
 func (h *Handler) HandleGetStats(w http.ResponseWriter, r *http.Request) {
-	slug := r.PathValue("slug")
-	if !isValidSlug(slug) {
+	postSlug := r.PathValue("slug")
+	if !slug.IsValid(postSlug) {
 		HttpErrorResponse(w, "invalid slug format", http.StatusBadRequest)
 		return
 	}
 
-	stats, err := h.repo.GetStats(r.Context(), slug)
+	stats, err := h.repo.GetStats(r.Context(), postSlug)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			json.NewEncoder(w).Encode(repo.Stats{Slug: slug, Views: 0, Likes: 0})
+			json.NewEncoder(w).Encode(repo.Stats{Slug: postSlug, Views: 0, Likes: 0})
 			return
 		}
 		h.log.Error("error getting stats", "error", err)
@@ -112,21 +120,21 @@ func (h *Handler) HandleGetStats(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) HandleView(w http.ResponseWriter, r *http.Request) {
-	slug := r.PathValue("slug")
-	if !isValidSlug(slug) {
+	postSlug := r.PathValue("slug")
+	if !slug.IsValid(postSlug) {
 		HttpErrorResponse(w, "invalid slug format", http.StatusBadRequest)
 		return
 	}
 
-	ip := GetClientIP(r)
+	ip := h.proxies.ClientIP(r)
 	if ip == "" {
 		HttpErrorResponse(w, "invalid request ip", http.StatusBadRequest)
 		return
 	}
 
-	stats, err := h.repo.IncrementViews(r.Context(), ip, slug)
+	stats, err := h.repo.IncrementViews(r.Context(), h.visitors.ID(ip), postSlug)
 	if err != nil {
-		h.log.Error("error incrementing view", "error", err, "slug", slug)
+		h.log.Error("error incrementing view", "error", err, "slug", postSlug)
 		HttpErrorResponse(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -136,19 +144,19 @@ func (h *Handler) HandleView(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) HandleLike(w http.ResponseWriter, r *http.Request) {
-	slug := r.PathValue("slug")
-	if !isValidSlug(slug) {
+	postSlug := r.PathValue("slug")
+	if !slug.IsValid(postSlug) {
 		HttpErrorResponse(w, "invalid slug format", http.StatusBadRequest)
 		return
 	}
 
-	ip := GetClientIP(r)
+	ip := h.proxies.ClientIP(r)
 	if ip == "" {
 		HttpErrorResponse(w, "invalid request ip", http.StatusBadRequest)
 		return
 	}
 
-	stats, err := h.repo.IncrementLikes(r.Context(), ip, slug)
+	stats, err := h.repo.IncrementLikes(r.Context(), h.visitors.ID(ip), postSlug)
 	if err != nil {
 		h.log.Error("error liking post", "error", err)
 		HttpErrorResponse(w, "internal server error", http.StatusInternalServerError)
@@ -159,6 +167,61 @@ func (h *Handler) HandleLike(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(stats)
 }
 
+// searchLimit is the number of results /api/search returns.
+const searchLimit = 10
+
+type SearchResult struct {
+	Slug string `json:"slug"`
+	URL  string `json:"url"`
+	Date string `json:"date"`
+
+	// Title and Snippet are HTML, with matched terms in <mark>.
+	Title   string `json:"title"`
+	Snippet string `json:"snippet"`
+}
+
+type SearchResponse struct {
+	Query   string         `json:"query"`
+	Results []SearchResult `json:"results"`
+}
+
+// highlightHTML escapes text from the search index and turns its highlight
+// markers into <mark> elements.
+func highlightHTML(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, repo.HighlightStart, "<mark>")
+	return strings.ReplaceAll(s, repo.HighlightEnd, "</mark>")
+}
+
+func (h *Handler) HandleSearch(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if !isValidSearchQuery(q) {
+		HttpErrorResponse(w, "invalid search query", http.StatusBadRequest)
+		return
+	}
+
+	matches, err := h.repo.Search(r.Context(), q, searchLimit)
+	if err != nil {
+		h.log.Error("error searching posts", "error", err, "query", q)
+		HttpErrorResponse(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	res := SearchResponse{Query: q, Results: []SearchResult{}}
+	for _, m := range matches {
+		res.Results = append(res.Results, SearchResult{
+			Slug:    m.Slug,
+			URL:     "/" + m.Slug + "/",
+			Date:    m.Date,
+			Title:   highlightHTML(m.Title),
+			Snippet: highlightHTML(m.Snippet),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
 var StartTime = time.Now()
 
 type SysStats struct {
@@ -191,7 +254,7 @@ func (h *Handler) HandleStreamStats(w http.ResponseWriter, r *http.Request) {
 		runtime.ReadMemStats(&m)
 
 		var dbSizeStr string
-		fileInfo, err := os.Stat("./blog.db")
+		fileInfo, err := os.Stat(h.dbPath)
 		if err == nil {
 			dbSizeStr = fmt.Sprintf("%.2f", float64(fileInfo.Size())/1024/1024)
 		} else {
@@ -0,0 +1,115 @@
+package handler_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	blogdb "github.com/thornhall/blog/internal/db"
+	"github.com/thornhall/blog/internal/handler"
+	"github.com/thornhall/blog/internal/repo"
+	_ "modernc.org/sqlite"
+)
+
+// newSearchHandler returns a handler whose search index holds docs.
+func newSearchHandler(t *testing.T, docs []repo.SearchDoc) *handler.Handler {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, blogdb.Migrate(t.Context(), db))
+
+	r := repo.New(db)
+	require.NoError(t, r.SyncSearch(t.Context(), docs))
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return handler.New(r, log, t.TempDir(), "", nil, nil)
+}
+
+func search(t *testing.T, h *handler.Handler, q string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/api/search?q="+url.QueryEscape(q), nil)
+	w := httptest.NewRecorder()
+	h.HandleSearch(w, r)
+	return w
+}
+
+func TestHandleSearchValidatesQuery(t *testing.T) {
+	h := newSearchHandler(t, nil)
+
+	tests := []struct {
+		name string
+		q    string
+		want int
+	}{
+		{name: "word", q: "sqlite", want: http.StatusOK},
+		{name: "surrounding space is trimmed", q: "  sqlite  ", want: http.StatusOK},
+		{name: "longest query", q: strings.Repeat("a", 100), want: http.StatusOK},
+		{name: "length counts characters, not bytes", q: strings.Repeat("é", 100), want: http.StatusOK},
+		{name: "non-ASCII letters", q: "日本語", want: http.StatusOK},
+		{name: "empty", q: "", want: http.StatusBadRequest},
+		{name: "only spaces", q: "   ", want: http.StatusBadRequest},
+		{name: "too long", q: strings.Repeat("a", 101), want: http.StatusBadRequest},
+		{name: "no letters or digits", q: `"*" + -`, want: http.StatusBadRequest},
+		{name: "control character", q: "sql\x00ite", want: http.StatusBadRequest},
+		{name: "newline", q: "sql\nite", want: http.StatusBadRequest},
+		{name: "invalid UTF-8", q: "sql\xffite", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := search(t, h, tt.q)
+			assert.Equal(t, tt.want, w.Code)
+			if tt.want == http.StatusBadRequest {
+				var res handler.ErrorResponse
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+				assert.Equal(t, "invalid search query", res.Message)
+			}
+		})
+	}
+}
+
+func TestHandleSearchEscapesResults(t *testing.T) {
+	h := newSearchHandler(t, []repo.SearchDoc{{
+		Slug:  "xss",
+		Date:  "Nov 19, 2025",
+		Title: `<script>alert(1)</script> SQLite & "friends"`,
+		Body:  `Escape <img src=x onerror=alert(1)> in backups.`,
+	}})
+
+	w := search(t, h, "sqlite")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var res handler.SearchResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+	assert.Equal(t, "sqlite", res.Query)
+	require.Len(t, res.Results, 1)
+
+	got := res.Results[0]
+	assert.Equal(t, "/xss/", got.URL)
+	assert.Equal(t, "&lt;script&gt;alert(1)&lt;/script&gt; <mark>SQLite</mark> &amp; &#34;friends&#34;", got.Title)
+
+	w = search(t, h, "backups")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+	require.Len(t, res.Results, 1)
+	assert.Contains(t, res.Results[0].Snippet, "&lt;img src=x onerror=alert(1)&gt; in <mark>backups</mark>")
+	assert.NotContains(t, res.Results[0].Snippet, "<img")
+}
+
+func TestHandleSearchNoResults(t *testing.T) {
+	h := newSearchHandler(t, nil)
+
+	w := search(t, h, "nothing")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"query": "nothing", "results": []}`, w.Body.String())
+}
@@ -3,6 +3,7 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
 type Repo struct {
@@ -36,7 +37,9 @@ func (r *Repo) GetStats(ctx context.Context, slug string) (Stats, error) {
 	return s, err
 }
 
-func (r *Repo) IncrementViews(ctx context.Context, ip, slug string) (Stats, error) {
+// IncrementViews counts a view of slug, once per visitor ID until the
+// visitor's row is purged.
+func (r *Repo) IncrementViews(ctx context.Context, visitorID, slug string) (Stats, error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return Stats{}, err
@@ -44,10 +47,10 @@ func (r *Repo) IncrementViews(ctx context.Context, ip, slug string) (Stats, erro
 	defer tx.Rollback()
 
 	res, err := tx.ExecContext(ctx, `
-        INSERT INTO ip_views (ip, post_slug) 
-        VALUES (?, ?) 
-        ON CONFLICT(ip, post_slug) DO NOTHING;
-    `, ip, slug)
+        INSERT INTO ip_views (visitor_id, post_slug, created_at) 
+        VALUES (?, ?, CURRENT_TIMESTAMP) 
+        ON CONFLICT(visitor_id, post_slug) DO NOTHING;
+    `, visitorID, slug)
 	if err != nil {
 		return Stats{}, err
 	}
@@ -74,7 +77,9 @@ func (r *Repo) IncrementViews(ctx context.Context, ip, slug string) (Stats, erro
 	return r.GetStats(ctx, slug)
 }
 
-func (r *Repo) IncrementLikes(ctx context.Context, ip, slug string) (Stats, error) {
+// IncrementLikes counts a like of slug, once per visitor ID until the
+// visitor's row is purged.
+func (r *Repo) IncrementLikes(ctx context.Context, visitorID, slug string) (Stats, error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return Stats{}, err
@@ -82,10 +87,10 @@ func (r *Repo) IncrementLikes(ctx context.Context, ip, slug string) (Stats, erro
 	defer tx.Rollback()
 
 	res, err := tx.ExecContext(ctx, `
-        INSERT INTO ip_likes (ip, post_slug) 
-        VALUES (?, ?) 
-        ON CONFLICT(ip, post_slug) DO NOTHING;
-    `, ip, slug)
+        INSERT INTO ip_likes (visitor_id, post_slug, created_at) 
+        VALUES (?, ?, CURRENT_TIMESTAMP) 
+        ON CONFLICT(visitor_id, post_slug) DO NOTHING;
+    `, visitorID, slug)
 	if err != nil {
 		return Stats{}, err
 	}
@@ -111,3 +116,90 @@ func (r *Repo) IncrementLikes(ctx context.Context, ip, slug string) (Stats, erro
 
 	return r.GetStats(ctx, slug)
 }
+
+// HashLegacyVisitors replaces the raw client addresses stored before visitor
+// IDs were hashed with hash(address). Rows that collide with an existing ID
+// for the same post are dropped, as that visitor was already counted. It
+// returns the number of rows converted.
+func (r *Repo) HashLegacyVisitors(ctx context.Context, hash func(ip string) string) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	converted := 0
+	for _, table := range []string{"ip_views", "ip_likes"} {
+		// Hashes are hex, so anything with an address separator is raw.
+		rows, err := tx.QueryContext(ctx, `
+            SELECT DISTINCT visitor_id FROM `+table+`
+            WHERE visitor_id LIKE '%.%' OR visitor_id LIKE '%:%';
+        `)
+		if err != nil {
+			return 0, err
+		}
+		var ips []string
+		for rows.Next() {
+			var ip string
+			if err := rows.Scan(&ip); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			ips = append(ips, ip)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+
+		for _, ip := range ips {
+			res, err := tx.ExecContext(ctx, `
+                UPDATE OR IGNORE `+table+` SET visitor_id = ? WHERE visitor_id = ?;
+            `, hash(ip), ip)
+			if err != nil {
+				return 0, err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return 0, err
+			}
+			converted += int(n)
+
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE visitor_id = ?;`, ip); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return converted, tx.Commit()
+}
+
+// PurgeVisitors deletes the view and like dedupe rows created before cutoff
+// and returns how many were deleted. The counts in post_stats are kept, but
+// a purged visitor can be counted again.
+func (r *Repo) PurgeVisitors(ctx context.Context, cutoff time.Time) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// created_at is stored in SQLite's CURRENT_TIMESTAMP format, which
+	// sorts as text.
+	before := cutoff.UTC().Format(time.DateTime)
+
+	var purged int64
+	for _, table := range []string{"ip_views", "ip_likes"} {
+		res, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE created_at < ?;`, before)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		purged += n
+	}
+
+	return purged, tx.Commit()
+}
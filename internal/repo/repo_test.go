@@ -2,66 +2,32 @@ package repo_test
 
 import (
 	"database/sql"
-	"log"
-	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	blogdb "github.com/thornhall/blog/internal/db"
 	"github.com/thornhall/blog/internal/repo"
 	_ "modernc.org/sqlite"
 )
 
-var db *sql.DB
-
-func setupTestDB() {
-	var err error
-	db, err = sql.Open("sqlite", ":memory:")
-	if err != nil {
-		log.Fatalf("failed to open db: %v", err)
-	}
-
-	query := `
-    CREATE TABLE post_stats (
-        slug TEXT PRIMARY KEY,
-        views INTEGER DEFAULT 0,
-        likes INTEGER DEFAULT 0
-    );`
-
-	if _, err := db.Exec(query); err != nil {
-		log.Fatalf("failed to create tables: %v", err)
-	}
-
-	query2 := `CREATE TABLE IF NOT EXISTS ip_likes (
-		ip TEXT,
-		post_slug TEXT REFERENCES post_stats(slug),
-
-		PRIMARY KEY (ip, post_slug)
-	);`
-
-	if _, err := db.Exec(query2); err != nil {
-		log.Fatalf("failed to create tables: %v", err)
-	}
-
-	query3 := `CREATE TABLE IF NOT EXISTS ip_views (
-		ip TEXT,
-		post_slug TEXT REFERENCES post_stats(slug),
-
-		PRIMARY KEY (ip, post_slug)
-	);`
-
-	if _, err := db.Exec(query3); err != nil {
-		log.Fatalf("failed to create tables: %v", err)
-	}
-}
-
-func TestMain(m *testing.M) {
-	setupTestDB()
-	code := m.Run()
-	db.Close()
-	os.Exit(code)
+// newTestDB returns a fresh migrated in-memory database. A single
+// connection keeps every query on the same database.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, blogdb.Migrate(t.Context(), db))
+	return db
 }
 
 func TestLikes(t *testing.T) {
+	db := newTestDB(t)
 	r := repo.New(db)
 	stats, err := r.GetStats(t.Context(), "random-slug")
 	assert.NoError(t, err)
@@ -77,6 +43,7 @@ func TestLikes(t *testing.T) {
 }
 
 func TestViews(t *testing.T) {
+	db := newTestDB(t)
 	r := repo.New(db)
 	stats, err := r.GetStats(t.Context(), "random-slug")
 	assert.NoError(t, err)
@@ -90,6 +57,7 @@ func TestViews(t *testing.T) {
 }
 
 func TestIsLiked(t *testing.T) {
+	db := newTestDB(t)
 	r := repo.New(db)
 
 	stats, err := r.GetStats(t.Context(), "random-slug")
@@ -106,6 +74,7 @@ func TestIsLiked(t *testing.T) {
 }
 
 func TestIsViewed(t *testing.T) {
+	db := newTestDB(t)
 	r := repo.New(db)
 
 	stats, err := r.GetStats(t.Context(), "random-slug")
@@ -120,3 +89,100 @@ func TestIsViewed(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 1, stats.Views)
 }
+
+func TestSearch(t *testing.T) {
+	db := newTestDB(t)
+	r := repo.New(db)
+
+	err := r.SyncSearch(t.Context(), []repo.SearchDoc{
+		{Slug: "backups", Date: "Nov 22, 2025", Title: "Backing up SQLite", Body: "Copy the database every hour."},
+		{Slug: "routing", Date: "Nov 20, 2025", Title: "Routing", Body: "Routes are matched before the SQLite handler runs."},
+	})
+	assert.NoError(t, err)
+
+	results, err := r.Search(t.Context(), "sqlite", 10)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, "backups", results[0].Slug, "title matches rank first")
+		assert.Equal(t, "Backing up "+repo.HighlightStart+"SQLite"+repo.HighlightEnd, results[0].Title)
+		assert.Contains(t, results[1].Snippet, repo.HighlightStart+"SQLite"+repo.HighlightEnd)
+	}
+
+	results, err = r.Search(t.Context(), `datab" OR`, 10)
+	assert.NoError(t, err, "operators in the query are treated as words")
+	assert.Empty(t, results)
+
+	results, err = r.Search(t.Context(), "datab", 10)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "backups", results[0].Slug)
+	}
+
+	// Syncing again replaces the table rather than adding to it.
+	assert.NoError(t, r.SyncSearch(t.Context(), []repo.SearchDoc{{Slug: "routing", Title: "Routing"}}))
+	results, err = r.Search(t.Context(), "sqlite", 10)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestHashLegacyVisitors(t *testing.T) {
+	db := newTestDB(t)
+	r := repo.New(db)
+	hash := func(ip string) string { return "hashed-" + strings.NewReplacer(".", "-", ":", "-").Replace(ip) }
+
+	for _, ip := range []string{"203.0.113.7", "2001:db8::"} {
+		_, err := db.Exec("INSERT INTO ip_views (visitor_id, post_slug) VALUES (?, 'legacy')", ip)
+		assert.NoError(t, err)
+	}
+	_, err := r.IncrementViews(t.Context(), hash("203.0.113.7"), "legacy")
+	assert.NoError(t, err)
+
+	n, err := r.HashLegacyVisitors(t.Context(), hash)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n, "the address that already has a hashed row is dropped, not converted")
+
+	var ids []string
+	rows, err := db.Query("SELECT visitor_id FROM ip_views WHERE post_slug = 'legacy' ORDER BY visitor_id")
+	assert.NoError(t, err)
+	for rows.Next() {
+		var id string
+		assert.NoError(t, rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	assert.NoError(t, rows.Close())
+	assert.Equal(t, []string{"hashed-2001-db8--", "hashed-203-0-113-7"}, ids)
+
+	// Converted visitors are still deduplicated.
+	stats, err := r.IncrementViews(t.Context(), hash("2001:db8::"), "legacy")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.Views)
+}
+
+func TestPurgeVisitors(t *testing.T) {
+	db := newTestDB(t)
+	r := repo.New(db)
+
+	_, err := r.IncrementViews(t.Context(), "old-visitor", "purged")
+	assert.NoError(t, err)
+	_, err = r.IncrementLikes(t.Context(), "old-visitor", "purged")
+	assert.NoError(t, err)
+	_, err = db.Exec("UPDATE ip_views SET created_at = '2025-01-01 00:00:00' WHERE post_slug = 'purged'")
+	assert.NoError(t, err)
+	_, err = db.Exec("UPDATE ip_likes SET created_at = '2025-01-01 00:00:00' WHERE post_slug = 'purged'")
+	assert.NoError(t, err)
+	_, err = r.IncrementViews(t.Context(), "new-visitor", "purged")
+	assert.NoError(t, err)
+
+	n, err := r.PurgeVisitors(t.Context(), time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	stats, err := r.GetStats(t.Context(), "purged")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Views, "counts survive the purge")
+	assert.Equal(t, 1, stats.Likes)
+
+	stats, err = r.IncrementViews(t.Context(), "new-visitor", "purged")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Views, "recent visitors are still deduplicated")
+}
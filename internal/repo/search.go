@@ -0,0 +1,110 @@
+package repo
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+// SearchDoc is a post as stored in the posts_fts full-text table.
+type SearchDoc struct {
+	Slug    string
+	Date    string
+	Title   string
+	Excerpt string
+	Body    string
+}
+
+// SearchResult is a post matching a search. Title and Snippet contain the
+// matched terms wrapped in HighlightStart and HighlightEnd and are otherwise
+// raw text; callers must escape them before rendering as HTML.
+type SearchResult struct {
+	Slug    string
+	Date    string
+	Title   string
+	Snippet string
+}
+
+// Markers SQLite inserts around matched terms. Control characters cannot
+// occur in post text, so they are safe to replace after escaping.
+const (
+	HighlightStart = "\x02"
+	HighlightEnd   = "\x03"
+)
+
+// SyncSearch replaces the contents of the full-text table with docs.
+func (r *Repo) SyncSearch(ctx context.Context, docs []SearchDoc) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM posts_fts;`); err != nil {
+		return err
+	}
+
+	for _, d := range docs {
+		_, err := tx.ExecContext(ctx, `
+            INSERT INTO posts_fts (slug, date, title, excerpt, body)
+            VALUES (?, ?, ?, ?, ?);
+        `, d.Slug, d.Date, d.Title, d.Excerpt, d.Body)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Search returns up to limit posts matching query, best match first. Title
+// matches rank above excerpt matches, which rank above body matches.
+func (r *Repo) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT slug, date,
+            highlight(posts_fts, 2, ?, ?),
+            snippet(posts_fts, -1, ?, ?, '…', 16)
+        FROM posts_fts
+        WHERE posts_fts MATCH ?
+        ORDER BY bm25(posts_fts, 0.0, 0.0, 10.0, 3.0, 1.0)
+        LIMIT ?;
+    `, HighlightStart, HighlightEnd, HighlightStart, HighlightEnd, match, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var res SearchResult
+		if err := rows.Scan(&res.Slug, &res.Date, &res.Title, &res.Snippet); err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
+
+// ftsQuery turns free text into an FTS5 query that cannot be a syntax
+// error: every word is quoted, so operators and punctuation are ignored, and
+// the last word matches as a prefix for search-as-you-type.
+func ftsQuery(query string) string {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return ""
+	}
+
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = `"` + w + `"`
+	}
+	quoted[len(quoted)-1] += "*"
+	return strings.Join(quoted, " ")
+}
@@ -8,14 +8,15 @@ import (
 	"github.com/thornhall/blog/internal/middleware"
 )
 
-func New(h *handler.Handler, log *slog.Logger, publicDir string) http.Handler {
+func New(h *handler.Handler, log *slog.Logger, publicDir, assetsDir string) http.Handler {
 	appMux := http.NewServeMux()
 	appMux.HandleFunc("POST /api/likes/{slug}", h.HandleLike)
 	appMux.HandleFunc("GET /api/stats/{slug}", h.HandleGetStats)
 	appMux.HandleFunc("POST /api/views/{slug}", h.HandleView)
+	appMux.HandleFunc("GET /api/search", h.HandleSearch)
 
 	fs := http.FileServer(http.Dir(publicDir))
-	assetsFs := http.FileServer(http.Dir("./assets"))
+	assetsFs := http.FileServer(http.Dir(assetsDir))
 	appMux.Handle("GET /assets/", http.StripPrefix("/assets/", assetsFs))
 	appMux.Handle("GET /", fs)
 
@@ -0,0 +1,85 @@
+package site
+
+import (
+	"html/template"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ArchiveYear is the posts of one year, grouped by month.
+type ArchiveYear struct {
+	Year   int
+	Months []ArchiveMonth
+}
+
+func (y ArchiveYear) Count() int {
+	n := 0
+	for _, m := range y.Months {
+		n += len(m.Posts)
+	}
+	return n
+}
+
+type ArchiveMonth struct {
+	Month time.Month
+	Posts []Post
+}
+
+// ArchivePage is the template data for both the full archive (/archive/)
+// and a single year (/archive/2025/). Years lists every year either way, for
+// navigation; Year is set on a year page.
+type ArchivePage struct {
+	Title   string
+	Excerpt string
+	Years   []ArchiveYear
+	Year    *ArchiveYear
+	Meta    PageMeta
+}
+
+// groupArchive groups posts by the year and month they were published.
+// posts must be sorted newest first, and the groups come out the same way.
+func groupArchive(posts []Post) []ArchiveYear {
+	var years []ArchiveYear
+	for _, post := range posts {
+		year, month := post.PublishedAt.Year(), post.PublishedAt.Month()
+
+		if len(years) == 0 || years[len(years)-1].Year != year {
+			years = append(years, ArchiveYear{Year: year})
+		}
+		y := &years[len(years)-1]
+
+		if len(y.Months) == 0 || y.Months[len(y.Months)-1].Month != month {
+			y.Months = append(y.Months, ArchiveMonth{Month: month})
+		}
+		m := &y.Months[len(y.Months)-1]
+		m.Posts = append(m.Posts, post)
+	}
+	return years
+}
+
+// writeArchive renders archive/index.html and archive/{year}/index.html for
+// every year with posts.
+func writeArchive(out *outputSet, tmpl *template.Template, site PageData) error {
+	years := groupArchive(site.Posts)
+	base := ArchivePage{
+		Title:   site.Title,
+		Excerpt: site.Excerpt,
+		Years:   years,
+		Meta:    site.Meta.at("/archive/", "Archive"),
+	}
+
+	if err := out.renderPage(tmpl, filepath.Join("archive", "index.html"), base); err != nil {
+		return err
+	}
+
+	for i := range years {
+		page := base
+		page.Year = &years[i]
+		page.Meta = site.Meta.at("/archive/"+strconv.Itoa(years[i].Year)+"/", "Archive: "+strconv.Itoa(years[i].Year))
+		if err := out.renderPage(tmpl, filepath.Join("archive", strconv.Itoa(years[i].Year), "index.html"), page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
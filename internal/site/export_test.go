@@ -0,0 +1,9 @@
+package site
+
+// ServePublic serves dir the way Watch does.
+var ServePublic = servePublic
+
+// NewReloadHub returns the hub Watch uses to signal browsers.
+var NewReloadHub = newReloadHub
+
+func (h *reloadHub) Broadcast() { h.broadcast() }
@@ -0,0 +1,275 @@
+package site
+
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+)
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	AtomLink      rssLink   `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+	readingStats
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle"`
+	ID       string      `xml:"id"`
+	Links    []atomLink  `xml:"link"`
+	Updated  string      `xml:"updated"`
+	Author   atomAuthor  `xml:"author"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary"`
+	Content    atomContent    `xml:"content"`
+	readingStats
+}
+
+// readingStats adds a post's length to RSS items and Atom entries. Neither
+// format has an element for it, so it lives in the site's own namespace,
+// {base URL}/ns/blog.
+type readingStats struct {
+	WordCount      nsInt `xml:"wordCount"`
+	ReadingMinutes nsInt `xml:"readingMinutes"`
+}
+
+// nsInt is an integer element whose namespace is only known at build time.
+type nsInt struct {
+	XMLName xml.Name
+	Value   int `xml:",chardata"`
+}
+
+func postReadingStats(p Post, baseURL string) readingStats {
+	ns := absURL(baseURL, "/ns/blog")
+	return readingStats{
+		WordCount:      nsInt{XMLName: xml.Name{Space: ns, Local: "wordCount"}, Value: p.WordCount},
+		ReadingMinutes: nsInt{XMLName: xml.Name{Space: ns, Local: "readingMinutes"}, Value: p.ReadingMinutes},
+	}
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Description string           `json:"description,omitempty"`
+	Language    string           `json:"language,omitempty"`
+	Authors     []jsonFeedAuthor `json:"authors,omitempty"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string            `json:"id"`
+	URL           string            `json:"url"`
+	Title         string            `json:"title"`
+	ContentHTML   string            `json:"content_html"`
+	ContentText   string            `json:"content_text,omitempty"`
+	Summary       string            `json:"summary,omitempty"`
+	DatePublished string            `json:"date_published"`
+	Tags          []string          `json:"tags,omitempty"`
+	Blog          jsonFeedExtension `json:"_blog"`
+}
+
+// jsonFeedExtension carries blog-specific fields that have no JSON Feed
+// equivalent. Extension keys must start with an underscore per the spec.
+type jsonFeedExtension struct {
+	Slug           string `json:"slug"`
+	Date           string `json:"date"`
+	Category       string `json:"category,omitempty"`
+	WordCount      int    `json:"word_count"`
+	ReadingMinutes int    `json:"reading_minutes"`
+}
+
+// absURL joins the site base URL with a site-relative path.
+func absURL(baseURL, path string) string {
+	return strings.TrimRight(baseURL, "/") + "/" + strings.TrimLeft(path, "/")
+}
+
+// postTerms returns the post's category followed by its tags, for feed
+// formats that only have a single flat list of categories.
+func postTerms(p Post) []string {
+	return append(postCategories(p), p.Tags...)
+}
+
+// lastUpdated returns the publish date of the newest post. Posts are expected
+// to already be sorted newest first. Using the post date rather than the
+// build time keeps the feeds byte-identical across rebuilds.
+func lastUpdated(posts []Post) time.Time {
+	if len(posts) == 0 {
+		return time.Time{}
+	}
+	return posts[0].PublishedAt
+}
+
+func buildRSS(data PageData, baseURL string) rssFeed {
+	channel := rssChannel{
+		Title:       data.Title,
+		Link:        absURL(baseURL, "/"),
+		Description: data.Excerpt,
+		Language:    "en-us",
+		AtomLink: rssLink{
+			Href: absURL(baseURL, "/feed.xml"),
+			Rel:  "self",
+			Type: "application/rss+xml",
+		},
+	}
+	if updated := lastUpdated(data.Posts); !updated.IsZero() {
+		channel.LastBuildDate = updated.Format(time.RFC1123Z)
+	}
+
+	for _, post := range data.Posts {
+		link := absURL(baseURL, "/"+post.Slug+"/")
+		channel.Items = append(channel.Items, rssItem{
+			Title:        post.Title,
+			Link:         link,
+			GUID:         rssGUID{IsPermaLink: true, Value: link},
+			PubDate:      post.PublishedAt.Format(time.RFC1123Z),
+			Categories:   postTerms(post),
+			Description:  string(post.Body),
+			readingStats: postReadingStats(post, baseURL),
+		})
+	}
+
+	return rssFeed{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		Channel: channel,
+	}
+}
+
+func buildAtom(data PageData, baseURL string) atomFeed {
+	feed := atomFeed{
+		Title:    data.Title,
+		Subtitle: data.Excerpt,
+		ID:       absURL(baseURL, "/"),
+		Links: []atomLink{
+			{Href: absURL(baseURL, "/atom.xml"), Rel: "self", Type: "application/atom+xml"},
+			{Href: absURL(baseURL, "/"), Rel: "alternate", Type: "text/html"},
+		},
+		Updated: lastUpdated(data.Posts).Format(time.RFC3339),
+		Author:  atomAuthor{Name: data.Author},
+	}
+
+	for _, post := range data.Posts {
+		link := absURL(baseURL, "/"+post.Slug+"/")
+		entry := atomEntry{
+			Title:     post.Title,
+			ID:        link,
+			Link:      atomLink{Href: link, Rel: "alternate", Type: "text/html"},
+			Published: post.PublishedAt.Format(time.RFC3339),
+			Updated:   post.PublishedAt.Format(time.RFC3339),
+			Summary:   post.Excerpt,
+			Content:   atomContent{Type: "html", Value: string(post.Body)},
+
+			readingStats: postReadingStats(post, baseURL),
+		}
+		for _, term := range postTerms(post) {
+			entry.Categories = append(entry.Categories, atomCategory{Term: term})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return feed
+}
+
+func buildJSONFeed(data PageData, baseURL string) jsonFeed {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       data.Title,
+		HomePageURL: absURL(baseURL, "/"),
+		FeedURL:     absURL(baseURL, "/feed.json"),
+		Description: data.Excerpt,
+		Language:    "en-US",
+		Authors:     []jsonFeedAuthor{{Name: data.Author}},
+		Items:       []jsonFeedItem{},
+	}
+
+	for _, post := range data.Posts {
+		link := absURL(baseURL, "/"+post.Slug+"/")
+		item := jsonFeedItem{
+			ID:            link,
+			URL:           link,
+			Title:         post.Title,
+			ContentHTML:   string(post.Body),
+			ContentText:   post.text,
+			Summary:       post.Excerpt,
+			DatePublished: post.PublishedAt.Format(time.RFC3339),
+			Blog: jsonFeedExtension{
+				Slug:           post.Slug,
+				Date:           post.Date,
+				Category:       post.Category,
+				WordCount:      post.WordCount,
+				ReadingMinutes: post.ReadingMinutes,
+			},
+			Tags: postTerms(post),
+		}
+		feed.Items = append(feed.Items, item)
+	}
+
+	return feed
+}
@@ -0,0 +1,199 @@
+package site
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/thornhall/blog/internal/slug"
+	meta "github.com/yuin/goldmark-meta"
+	"github.com/yuin/goldmark/parser"
+	"gopkg.in/yaml.v2"
+)
+
+// frontMatter is the schema of the YAML block at the top of every post.
+// Unknown keys are rejected so typos surface at build time.
+type frontMatter struct {
+	Title     string  `yaml:"title"`
+	Slug      string  `yaml:"slug"`
+	Date      string  `yaml:"date"`
+	Category  string  `yaml:"category"`
+	Tags      tagList `yaml:"tags"`
+	Excerpt   string  `yaml:"excerpt"`
+	Draft     bool    `yaml:"draft"`
+	Image     string  `yaml:"image"`
+	PublishAt string  `yaml:"publish_at"`
+
+	// Series groups posts into a multi-part series. SeriesOrder is the
+	// 1-based part number; without it parts are ordered by date.
+	Series      string `yaml:"series"`
+	SeriesOrder int    `yaml:"series_order"`
+
+	// TOC set to false hides the table of contents. Nil means the default,
+	// which is to show it.
+	TOC *bool `yaml:"toc"`
+
+	unknown []string
+}
+
+// tagList is a list of tags, written either as a YAML list or as a single
+// comma-separated string ("tags: go, sqlite").
+type tagList []string
+
+func (t *tagList) UnmarshalYAML(unmarshal func(any) error) error {
+	var list []string
+	if err := unmarshal(&list); err == nil {
+		*t = list
+		return nil
+	}
+
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return errors.New("tags must be a list or a comma-separated string")
+	}
+	*t = nil
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			*t = append(*t, tag)
+		}
+	}
+	return nil
+}
+
+// frontMatterKeys is the set of keys declared on frontMatter.
+var frontMatterKeys = func() map[string]bool {
+	keys := make(map[string]bool)
+	t := reflect.TypeOf(frontMatter{})
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ","); name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}()
+
+// decodeFrontMatter reads the metadata goldmark-meta collected during
+// conversion into a frontMatter.
+func decodeFrontMatter(ctx parser.Context) (frontMatter, error) {
+	var fm frontMatter
+
+	raw, err := meta.TryGet(ctx)
+	if err != nil {
+		return fm, fmt.Errorf("front matter is not valid YAML: %w", err)
+	}
+	if len(raw) == 0 {
+		return fm, errors.New("front matter is missing")
+	}
+
+	// goldmark-meta only hands out an untyped map, so round-trip it through
+	// YAML to decode into the struct.
+	out, err := yaml.Marshal(raw)
+	if err != nil {
+		return fm, err
+	}
+	if err := yaml.Unmarshal(out, &fm); err != nil {
+		return fm, fmt.Errorf("front matter does not match schema: %w", err)
+	}
+
+	// Unknown keys are reported by toPost alongside the other validation
+	// problems rather than failing here.
+	for key := range raw {
+		if !frontMatterKeys[key] {
+			fm.unknown = append(fm.unknown, key)
+		}
+	}
+	sort.Strings(fm.unknown)
+
+	return fm, nil
+}
+
+// showTOC reports whether the post's table of contents should be rendered.
+func (fm frontMatter) showTOC() bool {
+	return fm.TOC == nil || *fm.TOC
+}
+
+// toPost validates the front matter and builds the Post for it. Every
+// problem found is reported, not just the first, each prefixed with file.
+func (fm frontMatter) toPost(file string, body template.HTML, now time.Time) (Post, error) {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{file}, args...)...))
+	}
+
+	for _, key := range fm.unknown {
+		fail("unknown front matter key %q", key)
+	}
+
+	if fm.Title == "" {
+		fail("missing required field %q", "title")
+	}
+
+	switch {
+	case fm.Slug == "":
+		fail("missing required field %q", "slug")
+	case !slug.IsValid(fm.Slug):
+		fail("slug %q must be 1-99 characters of a-z, 0-9 and '-'", fm.Slug)
+	}
+
+	var publishedAt time.Time
+	if fm.Date == "" {
+		fail("missing required field %q", "date")
+	} else if t, err := time.Parse(dateLayout, fm.Date); err != nil {
+		fail("invalid date %q (expected format %q)", fm.Date, dateLayout)
+	} else {
+		publishedAt = t
+	}
+
+	draft := fm.Draft
+	if fm.PublishAt != "" {
+		t, err := parsePublishAt(fm.PublishAt)
+		if err != nil {
+			fail("invalid publish_at %q (expected RFC 3339 or %q)", fm.PublishAt, dateLayout)
+		} else if t.After(now) {
+			draft = true
+		}
+	}
+
+	if fm.Image != "" && !isAbsoluteURL(fm.Image) && !strings.HasPrefix(fm.Image, "/") {
+		fail("image %q must be a site path starting with '/' or an absolute URL", fm.Image)
+	}
+
+	switch {
+	case fm.SeriesOrder < 0:
+		fail("series_order must be at least 1, got %d", fm.SeriesOrder)
+	case fm.SeriesOrder > 0 && fm.Series == "":
+		fail("series_order is set but series is not")
+	}
+
+	if fm.Category != "" && termSlug(fm.Category) == "" {
+		fail("category %q has no letters or digits", fm.Category)
+	}
+	for _, tag := range fm.Tags {
+		if termSlug(tag) == "" {
+			fail("tag %q has no letters or digits", tag)
+		}
+	}
+
+	if len(errs) > 0 {
+		return Post{}, errors.Join(errs...)
+	}
+
+	return Post{
+		Title:       fm.Title,
+		Slug:        fm.Slug,
+		Date:        fm.Date,
+		PublishedAt: publishedAt,
+		Category:    fm.Category,
+		Tags:        fm.Tags,
+		Draft:       draft,
+		Excerpt:     fm.Excerpt,
+		Body:        body,
+		Image:       fm.Image,
+		seriesName:  fm.Series,
+		seriesOrder: fm.SeriesOrder,
+	}, nil
+}
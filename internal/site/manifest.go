@@ -0,0 +1,241 @@
+package site
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// manifest is persisted between builds so unchanged markdown is not
+// re-rendered and stale outputs can be cleaned up.
+type manifest struct {
+	// BuildID identifies the builder binary that produced the cache. Any
+	// code change invalidates every cached render.
+	BuildID string `json:"build_id"`
+
+	// Sources maps a markdown file name to its content hash and what it
+	// rendered to.
+	Sources map[string]cachedSource `json:"sources"`
+
+	// Outputs maps every file written, relative to the output directory,
+	// to its content hash.
+	Outputs map[string]string `json:"outputs"`
+}
+
+type cachedSource struct {
+	Hash  string      `json:"hash"`
+	Front frontMatter `json:"front"`
+	Body  string      `json:"body"`
+	TOC   []TOCEntry  `json:"toc,omitempty"`
+	Text  string      `json:"text"`
+}
+
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// currentBuildID hashes the running executable. Go builds are reproducible,
+// so `go run` produces the same ID until the builder's code changes.
+func currentBuildID() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// loadManifest reads the manifest at path. A missing or unreadable manifest
+// is not an error; it just means a full build.
+func loadManifest(path string, logger *log.Logger) *manifest {
+	m := &manifest{
+		Sources: make(map[string]cachedSource),
+		Outputs: make(map[string]string),
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return m
+	}
+	if err := json.Unmarshal(raw, m); err != nil {
+		logger.Printf("Ignoring unreadable build manifest %s: %v", path, err)
+		return &manifest{
+			Sources: make(map[string]cachedSource),
+			Outputs: make(map[string]string),
+		}
+	}
+	return m
+}
+
+// cached returns the cached render of a source file if its hash matches and
+// it was produced by this exact builder.
+func (m *manifest) cached(buildID, name, hash string) (cachedSource, bool) {
+	if buildID == "" || m.BuildID != buildID {
+		return cachedSource{}, false
+	}
+	src, ok := m.Sources[name]
+	return src, ok && src.Hash == hash
+}
+
+func (m *manifest) save(path string) error {
+	raw, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// outputSet collects generated files in memory and writes them under root
+// once the whole build has rendered, skipping any whose content is already
+// on disk. It remembers what it wrote for the next build's manifest. Paths
+// passed to its methods are relative to root.
+type outputSet struct {
+	root      string
+	log       *log.Logger
+	previous  map[string]string
+	written   map[string]string
+	pending   []pendingFile
+	changed   int
+	unchanged int
+}
+
+type pendingFile struct {
+	name string
+	data []byte
+}
+
+func newOutputSet(root string, previous map[string]string, logger *log.Logger) *outputSet {
+	return &outputSet{
+		root:     root,
+		log:      logger,
+		previous: previous,
+		written:  make(map[string]string),
+	}
+}
+
+// add queues data to be written to name by flush.
+func (o *outputSet) add(name string, data []byte) {
+	o.written[filepath.ToSlash(name)] = hashBytes(data)
+	o.pending = append(o.pending, pendingFile{name: name, data: data})
+}
+
+// flush writes every queued file unless it already holds exactly its data.
+// Leaving identical files alone preserves their mtimes for rsync/scp.
+func (o *outputSet) flush() error {
+	for _, f := range o.pending {
+		path := filepath.Join(o.root, f.name)
+		if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, f.data) {
+			o.unchanged++
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, f.data, 0644); err != nil {
+			return err
+		}
+		o.changed++
+		o.log.Println("Generated:", path)
+	}
+	o.pending = nil
+	return nil
+}
+
+// renderPage executes tmpl with data into name.
+func (o *outputSet) renderPage(tmpl *template.Template, name string, data any) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+	o.add(name, buf.Bytes())
+	return nil
+}
+
+// writeXML marshals v as an indented XML document to name.
+func (o *outputSet) writeXML(name string, v any) error {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	o.add(name, buf.Bytes())
+	return nil
+}
+
+// writeJSON marshals v as an indented JSON document to name.
+func (o *outputSet) writeJSON(name string, v any) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	o.add(name, buf.Bytes())
+	return nil
+}
+
+// removeStale deletes files written by the previous build that this build
+// did not produce, such as pages of deleted posts, along with any
+// directories left empty.
+func (o *outputSet) removeStale() (int, error) {
+	var stale []string
+	for name := range o.previous {
+		if _, ok := o.written[name]; !ok {
+			stale = append(stale, name)
+		}
+	}
+	sort.Strings(stale)
+
+	for _, name := range stale {
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			continue
+		}
+		path := filepath.Join(o.root, filepath.FromSlash(name))
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return 0, err
+		}
+		o.log.Println("Removed stale:", path)
+
+		for dir := filepath.Dir(path); dir != o.root && dir != "."; dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	return len(stale), nil
+}
@@ -0,0 +1,69 @@
+package site
+
+import (
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	meta "github.com/yuin/goldmark-meta"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+)
+
+func init() {
+	styles.Register(chroma.MustNewStyle("custom-vscode", chroma.StyleEntries{
+		chroma.Text:       "#ffffff",
+		chroma.Background: "bg:#0e0e10",
+
+		chroma.Comment: "#595958 italic",
+
+		chroma.Punctuation: "#f5ce42",
+
+		chroma.Keyword:          "#f77575 bold",
+		chroma.KeywordNamespace: "#f77575 bold",
+		chroma.Operator:         "#f77575 bold",
+
+		chroma.NameFunction:         "#7ddafc",
+		chroma.NameBuiltin:          "#44e7f9",
+		chroma.NameVariable:         "#85f1fd italic",
+		chroma.NameVariableInstance: "#85f1fd",
+		chroma.NameAttribute:        "#61a1f0",
+		chroma.NameProperty:         "#61a1f0",
+		chroma.NameEntity:           "#44e7f9",
+
+		chroma.NameClass:   "#6dfbdc",
+		chroma.KeywordType: "#6dfbdc",
+		chroma.String:      "#f5ce42",
+		chroma.StringChar:  "#f5ce42",
+		chroma.LiteralDate: "#f5ce42",
+		chroma.Generic:     "#44e7f9",
+
+		chroma.Number:          "#f5ce42",
+		chroma.KeywordConstant: "#f5ce42 bold",
+		chroma.Literal:         "#f5ce42",
+		chroma.StringInterpol:  "#96fea8",
+		chroma.NameNamespace:   "#44e7f9",
+		chroma.Error:           "#ff5555 bg:#110000",
+	}))
+}
+
+// newMarkdown builds the markdown pipeline: GFM, YAML front matter, heading
+// IDs and syntax highlighting, followed by any extra options from Config.
+func newMarkdown(extra ...goldmark.Option) goldmark.Markdown {
+	opts := []goldmark.Option{
+		goldmark.WithExtensions(
+			extension.GFM,
+			meta.Meta,
+			highlighting.NewHighlighting(
+				highlighting.WithStyle("custom-vscode"),
+				highlighting.WithFormatOptions(html.WithLineNumbers(true), html.TabWidth(4)),
+			),
+		),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+		),
+		goldmark.WithRendererOptions(),
+	}
+	return goldmark.New(append(opts, extra...)...)
+}
@@ -0,0 +1,74 @@
+package site
+
+import (
+	"strings"
+	"time"
+)
+
+// PageMeta is what layout.html puts in a page's Open Graph and Twitter card
+// tags. Every page's template data carries one.
+type PageMeta struct {
+	SiteName    string
+	SiteURL     string
+	Title       string
+	Description string
+
+	// URL and Image are absolute, as the Open Graph protocol requires.
+	URL   string
+	Image string
+
+	// Type is "article" for posts, with PublishedTime in RFC 3339, and
+	// "website" for everything else.
+	Type          string
+	PublishedTime string
+}
+
+// at returns the metadata of the page at path, titled title, with the rest
+// inherited from m.
+func (m PageMeta) at(path, title string) PageMeta {
+	m.URL = absURL(m.SiteURL, path)
+	m.Title = title
+	return m
+}
+
+// forPost returns the metadata of a post page. The post's own image, if it
+// has one, is used in place of its generated social card.
+func (m PageMeta) forPost(p Post) PageMeta {
+	m = m.at("/"+p.Slug+"/", p.Title)
+	m.Type = "article"
+	m.PublishedTime = p.PublishedAt.Format(time.RFC3339)
+	if p.Excerpt != "" {
+		m.Description = p.Excerpt
+	}
+	m.Image = absURL(m.SiteURL, "/"+p.Slug+"/og.png")
+	if p.Image != "" {
+		m.Image = imageURL(m.SiteURL, p.Image)
+	}
+	return m
+}
+
+// siteMeta is the metadata of the home page, which every other page's
+// metadata is derived from.
+func (b *Builder) siteMeta() PageMeta {
+	return PageMeta{
+		SiteName:    b.cfg.Title,
+		SiteURL:     b.cfg.BaseURL,
+		Title:       b.cfg.Title,
+		Description: b.cfg.Excerpt,
+		URL:         absURL(b.cfg.BaseURL, "/"),
+		Image:       imageURL(b.cfg.BaseURL, b.cfg.Image),
+		Type:        "website",
+	}
+}
+
+// imageURL resolves an image given as a site path or an absolute URL.
+func imageURL(baseURL, image string) string {
+	if image == "" || isAbsoluteURL(image) {
+		return image
+	}
+	return absURL(baseURL, image)
+}
+
+func isAbsoluteURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
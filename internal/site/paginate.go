@@ -0,0 +1,53 @@
+package site
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// pageURL is the site path of the nth (1-based) index page.
+func pageURL(n int) string {
+	if n == 1 {
+		return "/"
+	}
+	return fmt.Sprintf("/page/%d/", n)
+}
+
+// pageFile is where the nth index page is written, relative to the output
+// directory.
+func pageFile(n int) string {
+	if n == 1 {
+		return "index.html"
+	}
+	return filepath.Join("page", fmt.Sprint(n), "index.html")
+}
+
+// paginate splits data.Posts into pages of at most size posts. Each returned
+// PageData carries its own slice of posts and the links to its neighbours.
+// A blog with no posts still gets a single, empty first page.
+func paginate(data PageData, size int) []PageData {
+	total := (len(data.Posts) + size - 1) / size
+	if total == 0 {
+		total = 1
+	}
+
+	pages := make([]PageData, 0, total)
+	for n := 1; n <= total; n++ {
+		start := (n - 1) * size
+		end := min(start+size, len(data.Posts))
+
+		page := data
+		page.Posts = data.Posts[start:end]
+		page.Page = n
+		page.TotalPages = total
+		page.TotalPosts = len(data.Posts)
+		if n > 1 {
+			page.PrevURL = pageURL(n - 1)
+		}
+		if n < total {
+			page.NextURL = pageURL(n + 1)
+		}
+		pages = append(pages, page)
+	}
+	return pages
+}
@@ -0,0 +1,204 @@
+package site
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// relatedCount is how many related posts each post lists.
+const relatedCount = 3
+
+// Weights of the shared-taxonomy signals, added to the cosine similarity of
+// the post bodies (which is between 0 and 1).
+const (
+	sameCategoryWeight = 0.1
+	sharedTagWeight    = 0.25
+)
+
+// PostSummary is the part of a Post shown in a listing.
+type PostSummary struct {
+	Title          string
+	Slug           string
+	Date           string
+	Category       string
+	Excerpt        string
+	ReadingMinutes int
+}
+
+func summarize(p Post) PostSummary {
+	return PostSummary{
+		Title:          p.Title,
+		Slug:           p.Slug,
+		Date:           p.Date,
+		Category:       p.Category,
+		Excerpt:        p.Excerpt,
+		ReadingMinutes: p.ReadingMinutes,
+	}
+}
+
+// stopWords are too common to say anything about what a post is about.
+var stopWords = func() map[string]bool {
+	words := make(map[string]bool)
+	for _, w := range strings.Fields(`
+		a about after all also an and any are as at be because been but by can
+		could did do does doing don for from get got had has have how i if in
+		into is it its just like make me more most my need no not now of on one
+		only or other our out over so some such than that the their them then
+		there these they this those through to too up us use used using very
+		want was way we well were what when where which while who why will with
+		would you your`) {
+		words[w] = true
+	}
+	return words
+}()
+
+// tokenize splits text into lowercase words, dropping stop words and
+// anything shorter than three characters.
+func tokenize(text string) []string {
+	var tokens []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(word) < 3 || stopWords[word] {
+			continue
+		}
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
+
+// termVector is a sparse TF-IDF vector, sorted by term so that sums are
+// always taken in the same order and builds are reproducible.
+type termVector []termWeight
+
+type termWeight struct {
+	term   string
+	weight float64
+}
+
+// tfidfVectors returns a unit-length TF-IDF vector for each document.
+func tfidfVectors(docs [][]string) []termVector {
+	docFreq := make(map[string]int)
+	counts := make([]map[string]int, len(docs))
+	for i, doc := range docs {
+		counts[i] = make(map[string]int)
+		for _, term := range doc {
+			if counts[i][term] == 0 {
+				docFreq[term]++
+			}
+			counts[i][term]++
+		}
+	}
+
+	vectors := make([]termVector, len(docs))
+	for i, doc := range docs {
+		var v termVector
+		for term, n := range counts[i] {
+			tf := float64(n) / float64(len(doc))
+			idf := math.Log(float64(len(docs)) / float64(docFreq[term]))
+			if idf > 0 {
+				v = append(v, termWeight{term, tf * idf})
+			}
+		}
+		sort.Slice(v, func(a, b int) bool { return v[a].term < v[b].term })
+
+		var norm float64
+		for _, tw := range v {
+			norm += tw.weight * tw.weight
+		}
+		if norm > 0 {
+			norm = math.Sqrt(norm)
+			for j := range v {
+				v[j].weight /= norm
+			}
+		}
+		vectors[i] = v
+	}
+	return vectors
+}
+
+// cosine returns the cosine similarity of two unit vectors.
+func cosine(a, b termVector) float64 {
+	var dot float64
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i].term < b[j].term:
+			i++
+		case a[i].term > b[j].term:
+			j++
+		default:
+			dot += a[i].weight * b[j].weight
+			i++
+			j++
+		}
+	}
+	return dot
+}
+
+// relatePosts fills in Related for every post, scoring each pair by the
+// TF-IDF similarity of their text plus a bonus for a shared category and for
+// each shared tag. Ties go to the newer post, then the lower slug.
+func relatePosts(posts []Post) {
+	docs := make([][]string, len(posts))
+	for i, p := range posts {
+		docs[i] = tokenize(p.Title + " " + p.text)
+	}
+	vectors := tfidfVectors(docs)
+
+	type candidate struct {
+		index int
+		score float64
+	}
+
+	for i := range posts {
+		var candidates []candidate
+		for j := range posts {
+			if i == j {
+				continue
+			}
+			score := cosine(vectors[i], vectors[j])
+			if posts[i].Category != "" && termSlug(posts[i].Category) == termSlug(posts[j].Category) {
+				score += sameCategoryWeight
+			}
+			score += sharedTagWeight * float64(sharedTags(posts[i], posts[j]))
+			if score > 0 {
+				candidates = append(candidates, candidate{j, score})
+			}
+		}
+
+		sort.Slice(candidates, func(a, b int) bool {
+			pa, pb := posts[candidates[a].index], posts[candidates[b].index]
+			switch {
+			case candidates[a].score != candidates[b].score:
+				return candidates[a].score > candidates[b].score
+			case !pa.PublishedAt.Equal(pb.PublishedAt):
+				return pa.PublishedAt.After(pb.PublishedAt)
+			}
+			return pa.Slug < pb.Slug
+		})
+
+		posts[i].Related = nil
+		for _, c := range candidates[:min(relatedCount, len(candidates))] {
+			posts[i].Related = append(posts[i].Related, summarize(posts[c.index]))
+		}
+	}
+}
+
+// sharedTags counts the tags two posts have in common, compared by slug.
+func sharedTags(a, b Post) int {
+	tags := make(map[string]bool, len(a.Tags))
+	for _, tag := range a.Tags {
+		tags[termSlug(tag)] = true
+	}
+	n := 0
+	for _, tag := range b.Tags {
+		if tags[termSlug(tag)] {
+			n++
+			delete(tags, termSlug(tag))
+		}
+	}
+	return n
+}
@@ -0,0 +1,115 @@
+package site
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Weights of each field in the search index. A term's weight in a post is
+// the sum over every occurrence, so a word in the title outweighs a handful
+// of mentions in the body.
+const (
+	searchTitleWeight   = 10
+	searchHeadingWeight = 5
+	searchExcerptWeight = 3
+	searchTagWeight     = 3
+	searchBodyWeight    = 1
+)
+
+// searchIndex is written to search.json and queried by the script in
+// layout.html. Keys are kept short since every page that searches
+// downloads it.
+type searchIndex struct {
+	Docs []searchDoc `json:"docs"`
+
+	// Terms maps a stemmed term to [doc, weight] pairs, where doc is an
+	// index into Docs. Pairs are ordered by doc.
+	Terms map[string][][2]int `json:"terms"`
+
+	// Stop lists the words tokenize drops, so the script can drop them
+	// from queries too.
+	Stop []string `json:"stop"`
+}
+
+type searchDoc struct {
+	Title   string `json:"t"`
+	URL     string `json:"u"`
+	Date    string `json:"d"`
+	Excerpt string `json:"e,omitempty"`
+}
+
+// stem reduces a token from tokenize to a crude root by stripping common
+// English suffixes, so that "backups" finds "backup". It is deliberately
+// simple, because the script in layout.html has to apply the same rules to
+// queries; keep the two in sync.
+func stem(word string) string {
+	// Lengths are in code points, like [...word].length in the script; the
+	// suffixes are ASCII so slicing by byte is still safe.
+	n := utf8.RuneCountInString(word)
+	cut := func(suffix int) string { return word[:len(word)-suffix] }
+	switch {
+	case n > 4 && strings.HasSuffix(word, "ies"):
+		return cut(3) + "y"
+	case strings.HasSuffix(word, "sses"):
+		return cut(2)
+	case n > 6 && strings.HasSuffix(word, "ing"):
+		return cut(3)
+	case n > 4 && strings.HasSuffix(word, "ed"):
+		return cut(2)
+	case n > 4 && strings.HasSuffix(word, "ly"):
+		return cut(2)
+	case n > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+		return cut(1)
+	}
+	return word
+}
+
+// flattenTOC returns the titles of every entry in toc, depth first.
+func flattenTOC(toc []TOCEntry) []string {
+	var titles []string
+	for _, e := range toc {
+		titles = append(titles, e.Title)
+		titles = append(titles, flattenTOC(e.Children)...)
+	}
+	return titles
+}
+
+// buildSearchIndex indexes the title, headings, excerpt, tags and body text
+// of every post.
+func buildSearchIndex(posts []Post) searchIndex {
+	index := searchIndex{
+		Docs:  make([]searchDoc, 0, len(posts)),
+		Terms: make(map[string][][2]int),
+		Stop:  slices.Sorted(maps.Keys(stopWords)),
+	}
+
+	for doc, post := range posts {
+		index.Docs = append(index.Docs, searchDoc{
+			Title:   post.Title,
+			URL:     "/" + post.Slug + "/",
+			Date:    post.Date,
+			Excerpt: post.Excerpt,
+		})
+
+		weights := make(map[string]int)
+		add := func(text string, weight int) {
+			for _, token := range tokenize(text) {
+				weights[stem(token)] += weight
+			}
+		}
+		add(post.Title, searchTitleWeight)
+		add(strings.Join(flattenTOC(post.TOC), " "), searchHeadingWeight)
+		add(post.Excerpt, searchExcerptWeight)
+		add(strings.Join(post.Tags, " "), searchTagWeight)
+		add(post.text, searchBodyWeight)
+
+		// Docs are visited in order, so each term's postings stay sorted.
+		for term, weight := range weights {
+			index.Terms[term] = append(index.Terms[term], [2]int{doc, weight})
+		}
+	}
+
+	return index
+}
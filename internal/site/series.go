@@ -0,0 +1,77 @@
+package site
+
+import "sort"
+
+// PostLink is a reference to another post, for navigation.
+type PostLink struct {
+	Title string
+	Slug  string
+}
+
+// Series places a post within the series it belongs to. Part is 1-based and
+// Prev and Next are nil at either end.
+type Series struct {
+	Name  string
+	Part  int
+	Total int
+	Prev  *PostLink
+	Next  *PostLink
+	Parts []PostLink
+}
+
+func postLink(p Post) *PostLink {
+	return &PostLink{Title: p.Title, Slug: p.Slug}
+}
+
+// linkPosts fills in the chronological and series navigation of every
+// post. posts must be sorted newest first, as loadPosts returns them.
+func linkPosts(posts []Post) {
+	for i := range posts {
+		if i > 0 {
+			posts[i].Newer = postLink(posts[i-1])
+		}
+		if i < len(posts)-1 {
+			posts[i].Older = postLink(posts[i+1])
+		}
+	}
+
+	// Series are read oldest first. Parts with a series_order come first,
+	// in that order; the rest follow by publish date.
+	bySeries := make(map[string][]int)
+	for i := len(posts) - 1; i >= 0; i-- {
+		if name := posts[i].seriesName; name != "" {
+			bySeries[name] = append(bySeries[name], i)
+		}
+	}
+
+	for name, members := range bySeries {
+		sort.SliceStable(members, func(a, b int) bool {
+			oa, ob := posts[members[a]].seriesOrder, posts[members[b]].seriesOrder
+			switch {
+			case oa == ob:
+				return false
+			case oa == 0:
+				return false
+			case ob == 0:
+				return true
+			}
+			return oa < ob
+		})
+
+		parts := make([]PostLink, len(members))
+		for n, i := range members {
+			parts[n] = *postLink(posts[i])
+		}
+
+		for n, i := range members {
+			s := &Series{Name: name, Part: n + 1, Total: len(members), Parts: parts}
+			if n > 0 {
+				s.Prev = &parts[n-1]
+			}
+			if n < len(members)-1 {
+				s.Next = &parts[n+1]
+			}
+			posts[i].Series = s
+		}
+	}
+}
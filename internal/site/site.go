@@ -0,0 +1,412 @@
+// Package site generates the static blog: post pages, the paginated index,
+// taxonomy and archive pages, feeds and the sitemap, from markdown in a
+// content directory and html/template files in a template directory.
+package site
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// dateLayout is the format of the `date` front matter field.
+const dateLayout = "Jan 02, 2006"
+
+// parsePublishAt parses the `publish_at` front matter field, which may be a
+// full RFC 3339 timestamp or a plain date in dateLayout (midnight UTC).
+func parsePublishAt(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(dateLayout, value)
+}
+
+type Post struct {
+	Title       string
+	Slug        string
+	Date        string
+	PublishedAt time.Time
+	Category    string
+	Tags        []string
+	Draft       bool
+	Excerpt     string
+	Body        template.HTML
+
+	// Image overrides the site's social preview image for this post. It
+	// is a site path or an absolute URL.
+	Image string
+
+	// TOC is the post's heading outline. It is empty when the post has no
+	// h2-h6 headings or sets `toc: false`.
+	TOC []TOCEntry
+
+	// WordCount excludes code blocks. ReadingMinutes is derived from it.
+	WordCount      int
+	ReadingMinutes int
+
+	// Series is nil for standalone posts. Newer and Older link to the
+	// neighbouring posts by publish date.
+	Series *Series
+	Newer  *PostLink
+	Older  *PostLink
+
+	// Related lists up to relatedCount similar posts, most similar first.
+	Related []PostSummary
+
+	Views int
+	Likes int
+
+	Meta PageMeta
+
+	// Set from front matter and the rendered source, for linkPosts,
+	// relatePosts and the search index.
+	seriesName  string
+	seriesOrder int
+	text        string
+}
+
+type PageData struct {
+	Title   string
+	Excerpt string
+	Author  string
+	Posts   []Post
+	Meta    PageMeta
+
+	// Pagination of the index. Page is 1-based; PrevURL and NextURL are
+	// empty on the first and last page respectively.
+	Page       int
+	TotalPages int
+	TotalPosts int
+	PrevURL    string
+	NextURL    string
+}
+
+// Config describes where a Builder reads from and writes to, and the site
+// metadata it renders with.
+type Config struct {
+	ContentDir  string
+	TemplateDir string
+	AssetsDir   string
+	OutputDir   string
+
+	// ManifestPath is where the incremental build manifest is kept. Empty
+	// disables incremental builds.
+	ManifestPath string
+
+	Title   string
+	Excerpt string
+	Author  string
+	BaseURL string
+
+	// Image is the default social preview image, as a site path or an
+	// absolute URL.
+	Image string
+
+	PageSize      int
+	IncludeDrafts bool
+
+	// Markdown options are applied after the defaults, so they can add
+	// extensions or override renderer settings. Options can't be compared
+	// between builds, so setting any disables the cache of rendered posts.
+	Markdown []goldmark.Option
+
+	// Now is used to decide whether scheduled posts are published.
+	// Defaults to time.Now.
+	Now func() time.Time
+
+	// Log receives progress output. Defaults to log.Default().
+	Log *log.Logger
+}
+
+type Builder struct {
+	cfg Config
+	md  goldmark.Markdown
+	log *log.Logger
+}
+
+// New validates cfg and returns a Builder for it.
+func New(cfg Config) (*Builder, error) {
+	if cfg.ContentDir == "" || cfg.TemplateDir == "" || cfg.OutputDir == "" {
+		return nil, errors.New("site: content, template and output directories are required")
+	}
+	if cfg.BaseURL == "" {
+		return nil, errors.New("site: base URL is required")
+	}
+	if cfg.PageSize < 1 {
+		return nil, errors.New("site: page size must be at least 1")
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	logger := cfg.Log
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return &Builder{
+		cfg: cfg,
+		md:  newMarkdown(cfg.Markdown...),
+		log: logger,
+	}, nil
+}
+
+var templateFuncs = template.FuncMap{
+	"termSlug": termSlug,
+	"add":      func(a, b int) int { return a + b },
+}
+
+// parseTemplate parses the named files from the template directory into a
+// template named after the first one, with the builder's helper functions
+// available.
+func (b *Builder) parseTemplate(names ...string) (*template.Template, error) {
+	files := make([]string, len(names))
+	for i, name := range names {
+		files[i] = filepath.Join(b.cfg.TemplateDir, name)
+	}
+	return template.New(names[0]).Funcs(templateFuncs).ParseFiles(files...)
+}
+
+// loadPosts reads, renders and validates every markdown file in the content
+// directory. Sources unchanged since the previous build are taken from the
+// manifest instead of being rendered again. The returned posts are sorted
+// newest first.
+func (b *Builder) loadPosts(prev, next *manifest) ([]Post, error) {
+	files, err := os.ReadDir(b.cfg.ContentDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read content directory: %w", err)
+	}
+
+	now := b.cfg.Now()
+	var posts []Post
+	var problems []error
+	slugFiles := make(map[string]string)
+
+	for _, file := range files {
+		if filepath.Ext(file.Name()) != ".md" {
+			continue
+		}
+		path := filepath.Join(b.cfg.ContentDir, file.Name())
+
+		source, err := os.ReadFile(path)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		hash := hashBytes(source)
+
+		src, ok := prev.cached(next.BuildID, file.Name(), hash)
+		if !ok {
+			// Parse and render separately, rather than with Convert, so
+			// the AST is available for the table of contents.
+			context := parser.NewContext()
+			doc := b.md.Parser().Parse(text.NewReader(source), parser.WithContext(context))
+
+			var buf bytes.Buffer
+			if err := b.md.Renderer().Render(&buf, source, doc); err != nil {
+				problems = append(problems, fmt.Errorf("%s: %w", path, err))
+				continue
+			}
+
+			fm, err := decodeFrontMatter(context)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("%s: %w", path, err))
+				continue
+			}
+			src = cachedSource{Hash: hash, Front: fm, Body: buf.String(), TOC: buildTOC(doc, source), Text: plainText(doc, source)}
+		}
+		fm := src.Front
+
+		if fm.Slug != "" {
+			if other, ok := slugFiles[fm.Slug]; ok {
+				problems = append(problems, fmt.Errorf("%s: duplicate slug %q (already used by %s)", path, fm.Slug, other))
+			} else {
+				slugFiles[fm.Slug] = path
+			}
+		}
+
+		p, err := fm.toPost(path, template.HTML(src.Body), now)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		next.Sources[file.Name()] = src
+
+		if fm.showTOC() {
+			p.TOC = src.TOC
+		}
+		p.WordCount = countWords(src.Text)
+		p.ReadingMinutes = readingMinutes(p.WordCount)
+		p.text = src.Text
+
+		if p.Draft && !b.cfg.IncludeDrafts {
+			b.log.Println("Skipped draft:", path)
+			continue
+		}
+
+		posts = append(posts, p)
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s failed validation, nothing was generated:\n%w", b.cfg.ContentDir, errors.Join(problems...))
+	}
+
+	sort.Slice(posts, func(i, j int) bool {
+		return posts[i].PublishedAt.After(posts[j].PublishedAt)
+	})
+	linkPosts(posts)
+	relatePosts(posts)
+	return posts, nil
+}
+
+// Build renders every page and feed into the output directory. Everything is
+// rendered in memory before anything is written, so a build that fails to
+// validate or render leaves the previous output untouched.
+func (b *Builder) Build() error {
+	prev := loadManifest(b.cfg.ManifestPath, b.log)
+	next := &manifest{
+		BuildID: currentBuildID(),
+		Sources: make(map[string]cachedSource),
+	}
+	if len(b.cfg.Markdown) > 0 {
+		// An empty ID never matches, so nothing is read from the cache
+		// and the next build without options starts fresh too.
+		next.BuildID = ""
+	}
+
+	posts, err := b.loadPosts(prev, next)
+	if err != nil {
+		return err
+	}
+
+	data := PageData{
+		Title:   b.cfg.Title,
+		Excerpt: b.cfg.Excerpt,
+		Author:  b.cfg.Author,
+		Posts:   posts,
+		Meta:    b.siteMeta(),
+	}
+
+	tmplIndex, err := b.parseTemplate("layout.html", "card.html", "index.html")
+	if err != nil {
+		return err
+	}
+
+	tmplPost, err := b.parseTemplate("layout.html", "post.html")
+	if err != nil {
+		return err
+	}
+
+	tmplTaxonomy, err := b.parseTemplate("layout.html", "card.html", "taxonomy.html")
+	if err != nil {
+		return err
+	}
+
+	tmplArchive, err := b.parseTemplate("layout.html", "archive.html")
+	if err != nil {
+		return err
+	}
+
+	tmplAbout, err := b.parseTemplate("layout.html", "about.html")
+	if err != nil {
+		return err
+	}
+
+	out := newOutputSet(b.cfg.OutputDir, prev.Outputs, b.log)
+
+	for _, page := range paginate(data, b.cfg.PageSize) {
+		if page.Page > 1 {
+			page.Meta = data.Meta.at(pageURL(page.Page), fmt.Sprintf("%s (page %d)", data.Title, page.Page))
+		}
+		if err := out.renderPage(tmplIndex, pageFile(page.Page), page); err != nil {
+			return err
+		}
+	}
+
+	if err := out.writeXML("feed.xml", buildRSS(data, b.cfg.BaseURL)); err != nil {
+		return err
+	}
+
+	if err := out.writeXML("atom.xml", buildAtom(data, b.cfg.BaseURL)); err != nil {
+		return err
+	}
+
+	if err := out.writeJSON("feed.json", buildJSONFeed(data, b.cfg.BaseURL)); err != nil {
+		return err
+	}
+
+	searchJSON, err := json.Marshal(buildSearchIndex(posts))
+	if err != nil {
+		return err
+	}
+	out.add("search.json", searchJSON)
+
+	if err := out.writeXML("sitemap.xml", buildSitemap(data, b.cfg.BaseURL)); err != nil {
+		return err
+	}
+
+	out.add("robots.txt", robotsTxt(b.cfg.BaseURL))
+
+	for _, post := range posts {
+		post.Meta = data.Meta.forPost(post)
+		if err := out.renderPage(tmplPost, filepath.Join(post.Slug, "index.html"), post); err != nil {
+			return err
+		}
+
+		card, err := renderSocialCard(post, b.cfg.Title)
+		if err != nil {
+			return fmt.Errorf("render social card for %s: %w", post.Slug, err)
+		}
+		out.add(filepath.Join(post.Slug, "og.png"), card)
+	}
+
+	categories := groupTerms(posts, postCategories)
+	if err := writeTaxonomy(out, tmplTaxonomy, data, "category", "Category", "Categories", categories); err != nil {
+		return err
+	}
+
+	tags := groupTerms(posts, postTags)
+	if err := writeTaxonomy(out, tmplTaxonomy, data, "tag", "Tag", "Tags", tags); err != nil {
+		return err
+	}
+
+	if err := writeArchive(out, tmplArchive, data); err != nil {
+		return err
+	}
+
+	about := data
+	about.Meta = data.Meta.at("/about/", "About")
+	if err := out.renderPage(tmplAbout, filepath.Join("about", "index.html"), about); err != nil {
+		return err
+	}
+
+	if err := out.flush(); err != nil {
+		return err
+	}
+
+	removed, err := out.removeStale()
+	if err != nil {
+		return err
+	}
+
+	next.Outputs = out.written
+	if b.cfg.ManifestPath != "" {
+		if err := next.save(b.cfg.ManifestPath); err != nil {
+			return fmt.Errorf("could not save build manifest: %w", err)
+		}
+	}
+
+	b.log.Printf("Build complete: %d written, %d unchanged, %d removed", out.changed, out.unchanged, removed)
+	return nil
+}
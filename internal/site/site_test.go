@@ -0,0 +1,411 @@
+package site_test
+
+import (
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thornhall/blog/internal/site"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+func writePost(t *testing.T, dir, name, frontMatter string) {
+	t.Helper()
+	src := "---\n" + frontMatter + "\n---\nHello from " + name + ".\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(src), 0644))
+}
+
+func newTestBuilder(t *testing.T) (*site.Builder, site.Config) {
+	t.Helper()
+	root := t.TempDir()
+
+	cfg := site.Config{
+		ContentDir:   filepath.Join(root, "content"),
+		TemplateDir:  "../../templates",
+		OutputDir:    filepath.Join(root, "public"),
+		ManifestPath: filepath.Join(root, "manifest.json"),
+		Title:        "Test",
+		BaseURL:      "https://example.com",
+		Image:        "/assets/preview.png",
+		PageSize:     10,
+		Now:          func() time.Time { return time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC) },
+		Log:          log.New(io.Discard, "", 0),
+	}
+	require.NoError(t, os.MkdirAll(cfg.ContentDir, 0755))
+
+	b, err := site.New(cfg)
+	require.NoError(t, err)
+	return b, cfg
+}
+
+func TestBuild(t *testing.T) {
+	b, cfg := newTestBuilder(t)
+	writePost(t, cfg.ContentDir, "first.md", "title: First\nslug: first\ndate: Nov 19, 2025\ncategory: Engineering\ntags: [go]")
+	writePost(t, cfg.ContentDir, "second.md", "title: Second\nslug: second\ndate: Nov 20, 2025\ncategory: Engineering\ntags: go, sqlite")
+	writePost(t, cfg.ContentDir, "draft.md", "title: Draft\nslug: draft\ndate: Nov 21, 2025\ndraft: true")
+	writePost(t, cfg.ContentDir, "later.md", "title: Later\nslug: later\ndate: Nov 22, 2025\npublish_at: 2026-01-01T00:00:00Z")
+
+	require.NoError(t, b.Build())
+
+	for _, name := range []string{"index.html", "first/index.html", "second/index.html", "tag/go/index.html", "tag/sqlite/index.html", "category/engineering/index.html", "feed.xml", "atom.xml", "feed.json", "sitemap.xml", "robots.txt"} {
+		assert.FileExists(t, filepath.Join(cfg.OutputDir, name))
+	}
+	assert.NoDirExists(t, filepath.Join(cfg.OutputDir, "draft"))
+	assert.NoDirExists(t, filepath.Join(cfg.OutputDir, "later"))
+
+	feed, err := os.ReadFile(filepath.Join(cfg.OutputDir, "feed.xml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(feed), "https://example.com/first/")
+	assert.NotContains(t, string(feed), "https://example.com/draft/")
+	assert.Contains(t, string(feed), "<readingMinutes xmlns=\"https://example.com/ns/blog\">1</readingMinutes>")
+	assert.NotContains(t, string(feed), "thorn.sh")
+
+	atom, err := os.ReadFile(filepath.Join(cfg.OutputDir, "atom.xml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(atom), "<wordCount xmlns=\"https://example.com/ns/blog\">3</wordCount>")
+}
+
+func TestBuildWithoutCategory(t *testing.T) {
+	b, cfg := newTestBuilder(t)
+	writePost(t, cfg.ContentDir, "a.md", "title: Uncategorized\nslug: a\ndate: Nov 19, 2025")
+
+	require.NoError(t, b.Build())
+
+	for _, name := range []string{"index.html", "a/index.html"} {
+		page, err := os.ReadFile(filepath.Join(cfg.OutputDir, name))
+		require.NoError(t, err)
+		assert.NotContains(t, string(page), `href="/category//"`, name)
+		assert.NotContains(t, string(page), "•  •", name)
+	}
+}
+
+func TestBuildNonASCIITerms(t *testing.T) {
+	b, cfg := newTestBuilder(t)
+	writePost(t, cfg.ContentDir, "a.md", "title: Unicode\nslug: a\ndate: Nov 19, 2025\ncategory: 日本語\ntags: [Café]")
+
+	require.NoError(t, b.Build())
+
+	assert.FileExists(t, filepath.Join(cfg.OutputDir, "category", "日本語", "index.html"))
+	assert.FileExists(t, filepath.Join(cfg.OutputDir, "tag", "café", "index.html"))
+
+	page, err := os.ReadFile(filepath.Join(cfg.OutputDir, "a", "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(page), `href="/category/%e6%97%a5%e6%9c%ac%e8%aa%9e/"`)
+	assert.Contains(t, string(page), `href="/tag/caf%c3%a9/"`)
+	assert.NotContains(t, string(page), `href="/category//"`)
+}
+
+func TestBuildIsIncremental(t *testing.T) {
+	b, cfg := newTestBuilder(t)
+	writePost(t, cfg.ContentDir, "first.md", "title: First\nslug: first\ndate: Nov 19, 2025")
+	writePost(t, cfg.ContentDir, "second.md", "title: Second\nslug: second\ndate: Nov 20, 2025")
+	writePost(t, cfg.ContentDir, "third.md", "title: Third\nslug: third\ndate: Nov 21, 2025")
+	require.NoError(t, b.Build())
+
+	// first only links to second, so removing third leaves its page as is.
+	page := filepath.Join(cfg.OutputDir, "first", "index.html")
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(page, old, old))
+
+	require.NoError(t, os.Remove(filepath.Join(cfg.ContentDir, "third.md")))
+	require.NoError(t, b.Build())
+
+	info, err := os.Stat(page)
+	require.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(old), "unchanged output should not be rewritten")
+	assert.NoDirExists(t, filepath.Join(cfg.OutputDir, "third"))
+}
+
+func TestBuildRenderErrorWritesNothing(t *testing.T) {
+	b, cfg := newTestBuilder(t)
+	writePost(t, cfg.ContentDir, "first.md", "title: First\nslug: first\ndate: Nov 19, 2025")
+	require.NoError(t, b.Build())
+	index := filepath.Join(cfg.OutputDir, "index.html")
+	before, err := os.ReadFile(index)
+	require.NoError(t, err)
+
+	// about.html is rendered last, after every other page.
+	cfg.TemplateDir = t.TempDir()
+	templates, err := filepath.Glob("../../templates/*.html")
+	require.NoError(t, err)
+	for _, name := range templates {
+		src, err := os.ReadFile(name)
+		require.NoError(t, err)
+		if filepath.Base(name) == "about.html" {
+			src = []byte(strings.Replace(string(src), `{{ define "content" }}`, `{{ define "content" }}{{ .NoSuchField }}`, 1))
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(cfg.TemplateDir, filepath.Base(name)), src, 0644))
+	}
+	writePost(t, cfg.ContentDir, "second.md", "title: Second\nslug: second\ndate: Nov 20, 2025")
+
+	b, err = site.New(cfg)
+	require.NoError(t, err)
+	require.Error(t, b.Build())
+
+	after, err := os.ReadFile(index)
+	require.NoError(t, err)
+	assert.True(t, string(before) == string(after), "the index from the last good build is kept")
+	assert.NoDirExists(t, filepath.Join(cfg.OutputDir, "second"))
+}
+
+func TestBuildPagination(t *testing.T) {
+	_, cfg := newTestBuilder(t)
+	cfg.PageSize = 1
+	b, err := site.New(cfg)
+	require.NoError(t, err)
+	for _, day := range []string{"19", "20", "21"} {
+		writePost(t, cfg.ContentDir, day+".md", "title: Post "+day+"\nslug: post-"+day+"\ndate: Nov "+day+", 2025")
+	}
+
+	require.NoError(t, b.Build())
+
+	first, err := os.ReadFile(filepath.Join(cfg.OutputDir, "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(first), "Page 1 of 3 · 3 posts")
+	assert.Contains(t, string(first), `<a href="/page/2/">Older →</a>`)
+	assert.NotContains(t, string(first), "← Newer")
+	assert.Contains(t, string(first), "Post 21")
+	assert.NotContains(t, string(first), "Post 20")
+
+	second, err := os.ReadFile(filepath.Join(cfg.OutputDir, "page", "2", "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(second), "Page 2 of 3")
+	assert.Contains(t, string(second), `<a href="/">← Newer</a>`)
+	assert.Contains(t, string(second), `<a href="/page/3/">Older →</a>`)
+	assert.Contains(t, string(second), "Post 20")
+	assert.Contains(t, string(second), `<link rel="canonical" href="https://example.com/page/2/">`)
+
+	last, err := os.ReadFile(filepath.Join(cfg.OutputDir, "page", "3", "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(last), `<a href="/page/2/">← Newer</a>`)
+	assert.NotContains(t, string(last), "Older →")
+	assert.NoDirExists(t, filepath.Join(cfg.OutputDir, "page", "4"))
+}
+
+func TestBuildEmptyBlogHasOnePage(t *testing.T) {
+	b, cfg := newTestBuilder(t)
+
+	require.NoError(t, b.Build())
+
+	page, err := os.ReadFile(filepath.Join(cfg.OutputDir, "index.html"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(page), `class="pagination-status"`)
+	assert.NoDirExists(t, filepath.Join(cfg.OutputDir, "page"))
+}
+
+func TestBuildMarkdownOptionsBypassCache(t *testing.T) {
+	_, cfg := newTestBuilder(t)
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "a.md"), []byte("---\ntitle: A\nslug: a\ndate: Nov 19, 2025\n---\nline one\nline two\n"), 0644))
+
+	b, err := site.New(cfg)
+	require.NoError(t, err)
+	require.NoError(t, b.Build())
+
+	cfg.Markdown = []goldmark.Option{goldmark.WithRendererOptions(html.WithHardWraps())}
+	b, err = site.New(cfg)
+	require.NoError(t, err)
+	require.NoError(t, b.Build())
+
+	page, err := os.ReadFile(filepath.Join(cfg.OutputDir, "a", "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(page), "line one<br>", "the cached render from the first build must not be reused")
+}
+
+func TestBuildReportsAllProblems(t *testing.T) {
+	b, cfg := newTestBuilder(t)
+	writePost(t, cfg.ContentDir, "a.md", "title: A\nslug: Not A Slug\ndate: 2025-11-19")
+	writePost(t, cfg.ContentDir, "b.md", "slug: dup\ndate: Nov 19, 2025")
+	writePost(t, cfg.ContentDir, "c.md", "title: C\nslug: dup\ndate: Nov 19, 2025\nslgu: typo\nimage: preview.png\ncategory: ???")
+
+	err := b.Build()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `a.md: slug "Not A Slug"`)
+	assert.Contains(t, err.Error(), `a.md: invalid date "2025-11-19"`)
+	assert.Contains(t, err.Error(), `b.md: missing required field "title"`)
+	assert.Contains(t, err.Error(), `c.md: duplicate slug "dup"`)
+	assert.Contains(t, err.Error(), `c.md: unknown front matter key "slgu"`)
+	assert.Contains(t, err.Error(), `c.md: image "preview.png" must be a site path`)
+	assert.Contains(t, err.Error(), `c.md: category "???" has no letters or digits`)
+	assert.NoDirExists(t, cfg.OutputDir)
+}
+
+func TestBuildTableOfContents(t *testing.T) {
+	b, cfg := newTestBuilder(t)
+	body := "\n## Setup\n\n### The `db` package\n\n## Results\n"
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "toc.md"), []byte("---\ntitle: TOC\nslug: toc\ndate: Nov 19, 2025\n---\n"+body), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "no-toc.md"), []byte("---\ntitle: No TOC\nslug: no-toc\ndate: Nov 19, 2025\ntoc: false\n---\n"+body), 0644))
+
+	require.NoError(t, b.Build())
+
+	page, err := os.ReadFile(filepath.Join(cfg.OutputDir, "toc", "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(page), `<a href="#setup">Setup</a>`)
+	assert.Contains(t, string(page), `<a href="#the-db-package">The db package</a>`)
+	assert.Contains(t, string(page), `<a href="#results">Results</a>`)
+
+	page, err = os.ReadFile(filepath.Join(cfg.OutputDir, "no-toc", "index.html"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(page), `class="toc"`)
+}
+
+func TestBuildWordCountSkipsCode(t *testing.T) {
+	b, cfg := newTestBuilder(t)
+	body := "\nOne two three.\n\n```go\nfunc notCounted() {}\n```\n\n- four\n- five `six`\n"
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "words.md"), []byte("---\ntitle: Words\nslug: words\ndate: Nov 19, 2025\n---\n"+body), 0644))
+
+	require.NoError(t, b.Build())
+
+	feed, err := os.ReadFile(filepath.Join(cfg.OutputDir, "feed.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(feed), `"word_count": 6`)
+}
+
+func TestBuildSeriesNavigation(t *testing.T) {
+	b, cfg := newTestBuilder(t)
+	writePost(t, cfg.ContentDir, "a.md", "title: Part A\nslug: a\ndate: Nov 19, 2025\nseries: Saga\nseries_order: 2")
+	writePost(t, cfg.ContentDir, "b.md", "title: Part B\nslug: b\ndate: Nov 20, 2025\nseries: Saga\nseries_order: 1")
+	writePost(t, cfg.ContentDir, "c.md", "title: Standalone\nslug: c\ndate: Nov 21, 2025")
+
+	require.NoError(t, b.Build())
+
+	page, err := os.ReadFile(filepath.Join(cfg.OutputDir, "a", "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(page), "part 2 of 2 in the series <strong>Saga</strong>")
+	assert.Contains(t, string(page), `<a href="/b/">← Part 1</a>`)
+	assert.Contains(t, string(page), `<a href="/b/" class="newer">`)
+
+	page, err = os.ReadFile(filepath.Join(cfg.OutputDir, "c", "index.html"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(page), `<aside class="series-box">`)
+	assert.Contains(t, string(page), `<a href="/b/" class="older">`)
+}
+
+func TestBuildRelatedPosts(t *testing.T) {
+	b, cfg := newTestBuilder(t)
+	writePost(t, cfg.ContentDir, "a.md", "title: SQLite backups\nslug: a\ndate: Nov 19, 2025\ntags: [sqlite]")
+	writePost(t, cfg.ContentDir, "b.md", "title: SQLite in Go\nslug: b\ndate: Nov 20, 2025\ntags: [sqlite, go]")
+	writePost(t, cfg.ContentDir, "c.md", "title: Gardening\nslug: c\ndate: Nov 21, 2025")
+
+	require.NoError(t, b.Build())
+	first, err := os.ReadFile(filepath.Join(cfg.OutputDir, "a", "index.html"))
+	require.NoError(t, err)
+	assert.Regexp(t, `(?s)<section class="related">.*href="/b/"`, string(first))
+
+	require.NoError(t, os.RemoveAll(cfg.OutputDir))
+	require.NoError(t, os.Remove(cfg.ManifestPath))
+	require.NoError(t, b.Build())
+	second, err := os.ReadFile(filepath.Join(cfg.OutputDir, "a", "index.html"))
+	require.NoError(t, err)
+	assert.Equal(t, string(first), string(second), "related posts should be reproducible")
+}
+
+func TestBuildSearchIndex(t *testing.T) {
+	b, cfg := newTestBuilder(t)
+	writePost(t, cfg.ContentDir, "a.md", "title: Database Backups\nslug: a\ndate: Nov 19, 2025\nexcerpt: Keeping copies.")
+	writePost(t, cfg.ContentDir, "b.md", "title: Routing\nslug: b\ndate: Nov 20, 2025")
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.ContentDir, "c.md"), []byte("---\ntitle: Notes\nslug: c\ndate: Nov 21, 2025\n---\nI backed up the backup.\n\n```\nrouting\n```\n"), 0644))
+
+	require.NoError(t, b.Build())
+
+	raw, err := os.ReadFile(filepath.Join(cfg.OutputDir, "search.json"))
+	require.NoError(t, err)
+	var index struct {
+		Docs []struct {
+			Title string `json:"t"`
+			URL   string `json:"u"`
+		} `json:"docs"`
+		Terms map[string][][2]int `json:"terms"`
+		Stop  []string            `json:"stop"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &index))
+
+	require.Len(t, index.Docs, 3)
+	assert.Equal(t, "/c/", index.Docs[0].URL)
+	assert.Equal(t, "/a/", index.Docs[2].URL)
+
+	// "Backups" in a title outweighs "backup" in a body; code is not indexed.
+	assert.Equal(t, [][2]int{{0, 1}, {2, 10}}, index.Terms["backup"])
+	assert.Equal(t, [][2]int{{1, 10}}, index.Terms["rout"])
+	assert.Equal(t, [][2]int{{2, 3}}, index.Terms["copy"])
+
+	// The script drops the same stop words from queries.
+	assert.Contains(t, index.Stop, "the")
+	assert.True(t, slices.IsSorted(index.Stop))
+	assert.NotContains(t, index.Terms, "the")
+}
+
+func TestBuildArchive(t *testing.T) {
+	b, cfg := newTestBuilder(t)
+	writePost(t, cfg.ContentDir, "a.md", "title: Older\nslug: a\ndate: Dec 30, 2024")
+	writePost(t, cfg.ContentDir, "b.md", "title: Newer\nslug: b\ndate: Nov 20, 2025")
+
+	require.NoError(t, b.Build())
+
+	page, err := os.ReadFile(filepath.Join(cfg.OutputDir, "archive", "index.html"))
+	require.NoError(t, err)
+	assert.Regexp(t, `(?s)November.*href="/b/".*December.*href="/a/"`, string(page))
+
+	page, err = os.ReadFile(filepath.Join(cfg.OutputDir, "archive", "2024", "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(page), `href="/a/"`)
+	assert.NotContains(t, string(page), `href="/b/"`)
+	assert.NoFileExists(t, filepath.Join(cfg.OutputDir, "archive", "2023", "index.html"))
+}
+
+func TestBuildSocialMeta(t *testing.T) {
+	b, cfg := newTestBuilder(t)
+	writePost(t, cfg.ContentDir, "a.md", "title: Plain\nslug: a\ndate: Nov 19, 2025\nexcerpt: About A.")
+	writePost(t, cfg.ContentDir, "b.md", "title: Pictured\nslug: b\ndate: Nov 20, 2025\nimage: /assets/b.png")
+
+	require.NoError(t, b.Build())
+
+	page, err := os.ReadFile(filepath.Join(cfg.OutputDir, "a", "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(page), `<title>Plain</title>`)
+	assert.Contains(t, string(page), `<meta property="og:type" content="article">`)
+	assert.Contains(t, string(page), `<meta property="og:title" content="Plain">`)
+	assert.Contains(t, string(page), `<meta property="og:description" content="About A.">`)
+	assert.Contains(t, string(page), `<meta property="og:url" content="https://example.com/a/">`)
+	assert.Contains(t, string(page), `<meta property="og:image" content="https://example.com/a/og.png">`)
+	assert.Contains(t, string(page), `<meta property="article:published_time" content="2025-11-19T00:00:00Z">`)
+
+	page, err = os.ReadFile(filepath.Join(cfg.OutputDir, "b", "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(page), `<meta name="twitter:image" content="https://example.com/assets/b.png">`)
+
+	page, err = os.ReadFile(filepath.Join(cfg.OutputDir, "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(page), `<meta property="og:type" content="website">`)
+	assert.NotContains(t, string(page), "article:published_time")
+	assert.Contains(t, string(page), `<title>Test</title>`)
+	assert.Contains(t, string(page), `<link rel="alternate" type="application/rss+xml" title="Test" href="/feed.xml">`)
+	assert.NotContains(t, string(page), "blog.info()")
+}
+
+func TestBuildSocialCard(t *testing.T) {
+	b, cfg := newTestBuilder(t)
+	writePost(t, cfg.ContentDir, "a.md", "title: A Title Long Enough That It Has To Wrap Onto Several Lines Of The Card\nslug: a\ndate: Nov 19, 2025\ncategory: Go")
+	writePost(t, cfg.ContentDir, "b.md", "title: "+strings.Repeat("Far Too Long For Any Card ", 20)+"Supercalifragilisticexpialidociouslyunbreakable\nslug: b\ndate: Nov 20, 2025")
+
+	require.NoError(t, b.Build())
+
+	for _, slug := range []string{"a", "b"} {
+		f, err := os.Open(filepath.Join(cfg.OutputDir, slug, "og.png"))
+		require.NoError(t, err)
+		img, err := png.Decode(f)
+		f.Close()
+		require.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 1200, 630), img.Bounds())
+	}
+}
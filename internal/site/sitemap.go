@@ -0,0 +1,44 @@
+package site
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// sitemapDate is the W3C date format used for <lastmod>.
+const sitemapDate = "2006-01-02"
+
+func buildSitemap(data PageData, baseURL string) sitemapURLSet {
+	var set sitemapURLSet
+
+	index := sitemapURL{Loc: absURL(baseURL, "/")}
+	if updated := lastUpdated(data.Posts); !updated.IsZero() {
+		index.LastMod = updated.Format(sitemapDate)
+	}
+	set.URLs = append(set.URLs, index, sitemapURL{Loc: absURL(baseURL, "/about/")})
+
+	for _, post := range data.Posts {
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:     absURL(baseURL, "/"+post.Slug+"/"),
+			LastMod: post.PublishedAt.Format(sitemapDate),
+		})
+	}
+
+	return set
+}
+
+// robotsTxt keeps crawlers out of the API and points them at the generated
+// sitemap.
+func robotsTxt(baseURL string) []byte {
+	return fmt.Appendf(nil, "User-agent: *\nAllow: /\nDisallow: /api/\n\nSitemap: %s\n", absURL(baseURL, "/sitemap.xml"))
+}
@@ -0,0 +1,201 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Social cards are the recommended Open Graph size.
+const (
+	cardWidth  = 1200
+	cardHeight = 630
+	cardMargin = 80
+)
+
+// Colours from the site's stylesheet in layout.html.
+var (
+	cardTop    = color.RGBA{0x16, 0x16, 0x18, 0xff}
+	cardBottom = color.RGBA{0x0a, 0x0a, 0x0c, 0xff}
+	cardBorder = color.RGBA{0x2e, 0x2e, 0x32, 0xff}
+	cardText   = color.RGBA{0xed, 0xed, 0xed, 0xff}
+	cardMuted  = color.RGBA{0xa1, 0xa1, 0xaa, 0xff}
+	cardAccent = color.RGBA{0x59, 0xf4, 0xff, 0xff}
+)
+
+// Card text sizes in pixels. Titles start at the largest size and shrink
+// until they fit.
+const (
+	cardSmallSize    = 28
+	cardTitleMaxSize = 72
+	cardTitleMinSize = 40
+)
+
+// cardFonts are the Go Mono faces, close to the site's Roboto Mono, parsed
+// once on first use.
+var cardFonts = sync.OnceValues(func() ([2]*opentype.Font, error) {
+	regular, err := opentype.Parse(gomono.TTF)
+	if err != nil {
+		return [2]*opentype.Font{}, err
+	}
+	bold, err := opentype.Parse(gomonobold.TTF)
+	if err != nil {
+		return [2]*opentype.Font{}, err
+	}
+	return [2]*opentype.Font{regular, bold}, nil
+})
+
+func newFace(f *opentype.Font, size float64) (font.Face, error) {
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+// drawText draws s in face with its baseline starting at (x, y).
+func drawText(img draw.Image, face font.Face, x, y int, s string, c color.Color) {
+	d := font.Drawer{Dst: img, Src: image.NewUniform(c), Face: face, Dot: fixed.P(x, y)}
+	d.DrawString(s)
+}
+
+// wrapText breaks s into lines no wider than width in face, at spaces where
+// possible.
+func wrapText(face font.Face, s string, width int) []string {
+	fits := func(line string) bool {
+		return font.MeasureString(face, line).Ceil() <= width
+	}
+
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		if line != "" && fits(line+" "+word) {
+			line += " " + word
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+
+		// Split words too long for a line of their own.
+		line = ""
+		for _, r := range word {
+			if line != "" && !fits(line+string(r)) {
+				lines = append(lines, line)
+				line = ""
+			}
+			line += string(r)
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// renderSocialCard draws the Open Graph image for a post: its category,
+// title, date and reading time and the site name, over the site's colours.
+func renderSocialCard(p Post, siteName string) ([]byte, error) {
+	fonts, err := cardFonts()
+	if err != nil {
+		return nil, fmt.Errorf("parse card font: %w", err)
+	}
+	regular, bold := fonts[0], fonts[1]
+
+	img := image.NewRGBA(image.Rect(0, 0, cardWidth, cardHeight))
+	for y := 0; y < cardHeight; y++ {
+		c := blend(cardTop, cardBottom, float64(y)/float64(cardHeight-1))
+		draw.Draw(img, image.Rect(0, y, cardWidth, y+1), image.NewUniform(c), image.Point{}, draw.Src)
+	}
+	strokeRect(img, image.Rect(30, 30, cardWidth-30, cardHeight-30), 2, cardBorder)
+	draw.Draw(img, image.Rect(30, 30, 42, cardHeight-30), image.NewUniform(cardAccent), image.Point{}, draw.Src)
+
+	small, err := newFace(regular, cardSmallSize)
+	if err != nil {
+		return nil, err
+	}
+	smallBold, err := newFace(bold, cardSmallSize)
+	if err != nil {
+		return nil, err
+	}
+
+	top := cardMargin + cardSmallSize
+	if p.Category != "" {
+		drawText(img, smallBold, cardMargin, top, strings.ToUpper(p.Category), cardAccent)
+	}
+
+	bottom := cardHeight - cardMargin
+	footer := p.Date
+	if p.ReadingMinutes > 0 {
+		footer = fmt.Sprintf("%s • %d min read", p.Date, p.ReadingMinutes)
+	}
+	drawText(img, small, cardMargin, bottom, footer, cardMuted)
+	drawText(img, smallBold, cardWidth-cardMargin-font.MeasureString(smallBold, siteName).Ceil(), bottom, siteName, cardAccent)
+
+	// Use the largest title that fits between the category and the
+	// footer, shrinking and finally truncating long titles.
+	titleTop := top + 40
+	space := bottom - cardSmallSize - 40 - titleTop
+	maxWidth := cardWidth - 2*cardMargin
+
+	var title font.Face
+	var lines []string
+	var lineHeight int
+	for size := cardTitleMaxSize; size >= cardTitleMinSize; size -= 8 {
+		if title, err = newFace(bold, float64(size)); err != nil {
+			return nil, err
+		}
+		lineHeight = size * 5 / 4
+		lines = wrapText(title, p.Title, maxWidth)
+		if len(lines)*lineHeight <= space {
+			break
+		}
+	}
+	if keep := space / lineHeight; len(lines) > keep {
+		lines = lines[:keep]
+		last := []rune(lines[keep-1])
+		for len(last) > 0 && font.MeasureString(title, string(last)+"…").Ceil() > maxWidth {
+			last = last[:len(last)-1]
+		}
+		lines[keep-1] = strings.TrimSpace(string(last)) + "…"
+	}
+
+	ascent := title.Metrics().Ascent.Ceil()
+	for i, line := range lines {
+		drawText(img, title, cardMargin, titleTop+ascent+i*lineHeight, line, cardText)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// blend mixes a and b, t of the way from a to b.
+func blend(a, b color.RGBA, t float64) color.RGBA {
+	mix := func(x, y uint8) uint8 {
+		return uint8(float64(x) + (float64(y)-float64(x))*t + 0.5)
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 0xff}
+}
+
+// strokeRect draws the outline of r, width pixels thick, inside r.
+func strokeRect(img draw.Image, r image.Rectangle, width int, c color.Color) {
+	ink := image.NewUniform(c)
+	for _, side := range []image.Rectangle{
+		image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+width),
+		image.Rect(r.Min.X, r.Max.Y-width, r.Max.X, r.Max.Y),
+		image.Rect(r.Min.X, r.Min.Y, r.Min.X+width, r.Max.Y),
+		image.Rect(r.Max.X-width, r.Min.Y, r.Max.X, r.Max.Y),
+	} {
+		draw.Draw(img, side, ink, image.Point{}, draw.Src)
+	}
+}
@@ -0,0 +1,123 @@
+package site
+
+import (
+	"html/template"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Term is a single category or tag together with the posts filed under it.
+type Term struct {
+	Name  string
+	Slug  string
+	Posts []Post
+}
+
+func (t Term) Count() int {
+	return len(t.Posts)
+}
+
+// TaxonomyPage is the template data for both a single term listing
+// (/tag/go/) and the index of every term of a kind (/tag/).
+type TaxonomyPage struct {
+	Title   string
+	Excerpt string
+	Kind    string
+	Plural  string
+	Path    string
+	Term    *Term
+	Terms   []Term
+	Meta    PageMeta
+}
+
+// termSlug turns a category or tag name into a URL path segment. Letters and
+// digits of any script are kept, so the segment may need escaping in a URL.
+func termSlug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+			b.WriteRune(r)
+			dash = false
+		case !dash && b.Len() > 0:
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// groupTerms collects posts under every term returned by names. Posts keep
+// their incoming order, so a newest-first slice yields newest-first listings.
+// Terms that only differ in case or punctuation are merged under the first
+// spelling seen.
+func groupTerms(posts []Post, names func(Post) []string) []Term {
+	bySlug := make(map[string]*Term)
+	for _, post := range posts {
+		for _, name := range names(post) {
+			slug := termSlug(name)
+			if slug == "" {
+				continue
+			}
+			t, ok := bySlug[slug]
+			if !ok {
+				t = &Term{Name: name, Slug: slug}
+				bySlug[slug] = t
+			}
+			t.Posts = append(t.Posts, post)
+		}
+	}
+
+	terms := make([]Term, 0, len(bySlug))
+	for _, t := range bySlug {
+		terms = append(terms, *t)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		return terms[i].Slug < terms[j].Slug
+	})
+	return terms
+}
+
+func postCategories(p Post) []string {
+	if p.Category == "" {
+		return nil
+	}
+	return []string{p.Category}
+}
+
+func postTags(p Post) []string {
+	return p.Tags
+}
+
+// writeTaxonomy renders the term index at {kind}/index.html and one listing
+// page per term at {kind}/{slug}/index.html.
+func writeTaxonomy(out *outputSet, tmpl *template.Template, site PageData, kind, name, plural string, terms []Term) error {
+	base := TaxonomyPage{
+		Title:   site.Title,
+		Excerpt: site.Excerpt,
+		Kind:    name,
+		Plural:  plural,
+		Path:    "/" + kind + "/",
+	}
+
+	index := base
+	index.Terms = terms
+	index.Meta = site.Meta.at(base.Path, "All "+plural)
+	if err := out.renderPage(tmpl, filepath.Join(kind, "index.html"), index); err != nil {
+		return err
+	}
+
+	for i := range terms {
+		page := base
+		page.Term = &terms[i]
+		page.Meta = site.Meta.at(base.Path+url.PathEscape(terms[i].Slug)+"/", name+": "+terms[i].Name)
+		if err := out.renderPage(tmpl, filepath.Join(kind, terms[i].Slug, "index.html"), page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
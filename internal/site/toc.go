@@ -0,0 +1,83 @@
+package site
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// TOCEntry is a heading in a post's table of contents. ID is the anchor
+// generated by parser.WithAutoHeadingID.
+type TOCEntry struct {
+	Title    string     `json:"title"`
+	ID       string     `json:"id"`
+	Level    int        `json:"level"`
+	Children []TOCEntry `json:"children,omitempty"`
+}
+
+// buildTOC collects the h2-h6 headings of a parsed document, nested by
+// level. The h1, if any, is the post title and is left out.
+func buildTOC(doc ast.Node, source []byte) []TOCEntry {
+	var flat []TOCEntry
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		if heading.Level < 2 {
+			return ast.WalkSkipChildren, nil
+		}
+		id, ok := heading.AttributeString("id")
+		if !ok {
+			return ast.WalkSkipChildren, nil
+		}
+		idBytes, _ := id.([]byte)
+		flat = append(flat, TOCEntry{
+			Title: headingText(heading, source),
+			ID:    string(idBytes),
+			Level: heading.Level,
+		})
+		return ast.WalkSkipChildren, nil
+	})
+	return nestTOC(flat)
+}
+
+// nestTOC turns a flat list of headings into a tree. A heading's children
+// are the deeper headings that follow it, up to the next heading at the
+// same level or above. A skipped level (h2 then h4) simply nests one deep.
+func nestTOC(flat []TOCEntry) []TOCEntry {
+	var entries []TOCEntry
+	for i := 0; i < len(flat); {
+		entry := flat[i]
+		j := i + 1
+		for j < len(flat) && flat[j].Level > entry.Level {
+			j++
+		}
+		entry.Children = nestTOC(flat[i+1 : j])
+		entries = append(entries, entry)
+		i = j
+	}
+	return entries
+}
+
+// headingText returns the plain text of a heading, without any markup from
+// inline code, emphasis or links.
+func headingText(n ast.Node, source []byte) string {
+	var buf bytes.Buffer
+	ast.Walk(n, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := n.(type) {
+		case *ast.Text:
+			buf.Write(t.Segment.Value(source))
+			if t.SoftLineBreak() {
+				buf.WriteByte(' ')
+			}
+		case *ast.String:
+			buf.Write(t.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return buf.String()
+}
@@ -0,0 +1,227 @@
+package site
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pollInterval is how often the watched directories are rescanned. Polling
+// keeps the builder free of platform-specific file notification APIs and is
+// plenty fast for a handful of markdown files.
+const pollInterval = 300 * time.Millisecond
+
+// liveReloadScript is injected into every HTML page served in watch mode.
+// It is never written to public/, so deployed pages are unaffected.
+const liveReloadScript = `<script>new EventSource("/__livereload").onmessage = () => location.reload();</script>`
+
+// fileStamp is what a file is compared by between polls.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// snapshot records a stamp for every file under dir. A missing directory
+// yields an empty snapshot rather than an error.
+func snapshot(dir string) map[string]fileStamp {
+	files := make(map[string]fileStamp)
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			files[p] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+		return nil
+	})
+	return files
+}
+
+func sameSnapshot(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for p, stamp := range a {
+		if b[p] != stamp {
+			return false
+		}
+	}
+	return true
+}
+
+// reloadHub fans a reload signal out to every connected browser tab.
+type reloadHub struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+}
+
+func newReloadHub() *reloadHub {
+	return &reloadHub{clients: make(map[chan struct{}]struct{})}
+}
+
+func (h *reloadHub) broadcast() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (h *reloadHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	flusher.Flush()
+
+	ch := make(chan struct{}, 1)
+	h.mu.Lock()
+	h.clients[ch] = struct{}{}
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.clients, ch)
+		h.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ch:
+			if _, err := fmt.Fprint(w, "data: reload\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// servePublic serves dir like http.FileServer, but injects the live reload
+// script into HTML pages.
+func servePublic(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean(r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "/") {
+			name = path.Join(name, "index.html")
+		}
+		if path.Ext(name) != ".html" {
+			files.ServeHTTP(w, r)
+			return
+		}
+
+		page, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			files.ServeHTTP(w, r)
+			return
+		}
+		page = bytes.Replace(page, []byte("</body>"), []byte(liveReloadScript+"\n</body>"), 1)
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(page)
+	})
+}
+
+// Watch serves the output directory on addr and rebuilds whenever the
+// content or template directories change. Changes to the assets directory
+// need no rebuild since assets are served straight from disk, so they only
+// trigger a reload. It returns once ctx is cancelled.
+func (b *Builder) Watch(ctx context.Context, addr string) error {
+	hub := newReloadHub()
+	mux := http.NewServeMux()
+	mux.Handle("GET /__livereload", hub)
+	if b.cfg.AssetsDir != "" {
+		mux.Handle("GET /assets/", http.StripPrefix("/assets/", http.FileServer(http.Dir(b.cfg.AssetsDir))))
+	}
+	mux.Handle("GET /", servePublic(b.cfg.OutputDir))
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		BaseContext: func(l net.Listener) context.Context {
+			return ctx
+		},
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+	}()
+
+	rebuildDirs := []string{b.cfg.ContentDir, b.cfg.TemplateDir}
+	watched := rebuildDirs
+	if b.cfg.AssetsDir != "" {
+		watched = append(watched, b.cfg.AssetsDir)
+	}
+	b.log.Printf("Watching %s; serving http://%s", strings.Join(watched, ", "), addr)
+
+	last := make(map[string]map[string]fileStamp, len(watched))
+	for _, dir := range watched {
+		last[dir] = snapshot(dir)
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-serveErr:
+			return err
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return srv.Shutdown(shutdownCtx)
+		case <-ticker.C:
+		}
+
+		rebuild, reload := false, false
+		for _, dir := range watched {
+			current := snapshot(dir)
+			if sameSnapshot(last[dir], current) {
+				continue
+			}
+			last[dir] = current
+			reload = true
+			if slices.Contains(rebuildDirs, dir) {
+				rebuild = true
+			}
+		}
+
+		if rebuild {
+			start := time.Now()
+			if err := b.Build(); err != nil {
+				b.log.Println("Build failed:", err)
+				continue
+			}
+			b.log.Printf("Rebuilt in %s", time.Since(start).Round(time.Millisecond))
+		}
+		if reload {
+			hub.broadcast()
+		}
+	}
+}
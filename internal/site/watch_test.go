@@ -0,0 +1,163 @@
+package site_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thornhall/blog/internal/site"
+)
+
+const liveReload = `new EventSource("/__livereload")`
+
+func TestServePublic(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"index.html":       "<html><body>home</body></html>",
+		"post/index.html":  "<html><body>post</body></html>",
+		"feed.xml":         "<rss></rss>",
+		"assets/site.css":  "body { color: red; }",
+		"plain/notes.html": "no closing body tag",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(body), 0644))
+	}
+	srv := httptest.NewServer(site.ServePublic(dir))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+		wantScript bool
+	}{
+		{path: "/", wantStatus: http.StatusOK, wantBody: "home", wantScript: true},
+		{path: "/post/", wantStatus: http.StatusOK, wantBody: "post", wantScript: true},
+		{path: "/plain/notes.html", wantStatus: http.StatusOK, wantBody: "no closing body tag"},
+		{path: "/feed.xml", wantStatus: http.StatusOK, wantBody: "<rss></rss>"},
+		{path: "/assets/site.css", wantStatus: http.StatusOK, wantBody: "body { color: red; }"},
+		{path: "/missing/", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			res, err := http.Get(srv.URL + tt.path)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			assert.Contains(t, string(body), tt.wantBody)
+			if tt.wantScript {
+				assert.Contains(t, string(body), liveReload+`.onmessage = () => location.reload();</script>`+"\n</body>")
+				assert.Equal(t, "text/html; charset=utf-8", res.Header.Get("Content-Type"))
+			} else {
+				assert.Equal(t, tt.wantBody, string(body), "served unchanged")
+			}
+		})
+	}
+}
+
+// awaitReload reads the event stream at url until it sees a reload event,
+// calling poke repeatedly meanwhile. It fails the test after timeout.
+func awaitReload(t *testing.T, url string, timeout time.Duration, poke func()) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(t.Context(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	require.NoError(t, err)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	got := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(res.Body).ReadString('\n')
+		got <- line
+	}()
+
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case line := <-got:
+			assert.Equal(t, "data: reload\n", line)
+			return
+		case <-ctx.Done():
+			t.Fatal("no reload event before the timeout")
+		case <-ticker.C:
+			poke()
+		}
+	}
+}
+
+func TestReloadHubBroadcast(t *testing.T) {
+	hub := site.NewReloadHub()
+	srv := httptest.NewServer(hub)
+	t.Cleanup(srv.Close)
+
+	// The client registers just after the stream opens, so keep
+	// broadcasting until it hears one.
+	awaitReload(t, srv.URL, 5*time.Second, hub.Broadcast)
+}
+
+func TestWatchRebuildsAndReloads(t *testing.T) {
+	b, cfg := newTestBuilder(t)
+	writePost(t, cfg.ContentDir, "first.md", "title: First\nslug: first\ndate: Nov 19, 2025")
+	require.NoError(t, b.Build())
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() { done <- b.Watch(ctx, addr) }()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+
+	require.Eventually(t, func() bool {
+		res, err := http.Get("http://" + addr + "/first/")
+		if err != nil {
+			return false
+		}
+		res.Body.Close()
+		return res.StatusCode == http.StatusOK
+	}, 5*time.Second, 20*time.Millisecond)
+
+	wrote := false
+	awaitReload(t, "http://"+addr+"/__livereload", 10*time.Second, func() {
+		if !wrote {
+			writePost(t, cfg.ContentDir, "second.md", "title: Second\nslug: second\ndate: Nov 20, 2025")
+			wrote = true
+		}
+	})
+
+	res, err := http.Get("http://" + addr + "/second/")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.True(t, strings.Contains(string(body), liveReload), "rebuilt page is served with the reload script")
+}
@@ -0,0 +1,53 @@
+package site
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// wordsPerMinute is the reading speed used for ReadingMinutes.
+const wordsPerMinute = 230
+
+// plainText extracts the prose of a parsed document. Code blocks and raw
+// HTML are skipped; inline code is kept, since it is read as part of the
+// sentence around it.
+func plainText(doc ast.Node, source []byte) string {
+	var buf bytes.Buffer
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := n.(type) {
+		case *ast.FencedCodeBlock, *ast.CodeBlock, *ast.HTMLBlock, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			buf.Write(t.Segment.Value(source))
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				buf.WriteByte(' ')
+			}
+		case *ast.String:
+			buf.Write(t.Value)
+		default:
+			// Keep words in neighbouring blocks, such as list items,
+			// from running together.
+			if n.Type() == ast.TypeBlock {
+				buf.WriteByte(' ')
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+// countWords counts the words in text from plainText.
+func countWords(text string) int {
+	return len(strings.Fields(text))
+}
+
+// readingMinutes estimates the reading time for words, rounded up to a
+// whole minute and never less than one.
+func readingMinutes(words int) int {
+	return max(1, (words+wordsPerMinute-1)/wordsPerMinute)
+}
@@ -0,0 +1,13 @@
+// Package slug defines what a post slug may look like. The builder checks
+// posts against it and the server checks requests, so every generated page
+// is reachable through the stats API.
+package slug
+
+import "regexp"
+
+var slugRegex = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// IsValid reports whether s is a well-formed post slug.
+func IsValid(s string) bool {
+	return len(s) > 0 && len(s) < 100 && slugRegex.MatchString(s)
+}
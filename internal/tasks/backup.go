@@ -12,13 +12,15 @@ import (
 type BackupService struct {
 	spaceClient *backup.SpaceClient
 	dbPath      string
+	objectKey   string
 	interval    time.Duration
 }
 
-func NewBackupService(client *backup.SpaceClient, dbPath string, interval time.Duration) *BackupService {
+func NewBackupService(client *backup.SpaceClient, dbPath, objectKey string, interval time.Duration) *BackupService {
 	return &BackupService{
 		spaceClient: client,
 		dbPath:      dbPath,
+		objectKey:   objectKey,
 		interval:    interval,
 	}
 }
@@ -50,7 +52,5 @@ func (b *BackupService) performBackup(ctx context.Context) error {
 	}
 	defer f.Close()
 
-	filename := "backups/blog.db"
-
-	return b.spaceClient.UploadFile(ctx, filename, f)
+	return b.spaceClient.UploadFile(ctx, b.objectKey, f)
 }
@@ -0,0 +1,53 @@
+package tasks
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/thornhall/blog/internal/repo"
+)
+
+// PurgeService periodically deletes view and like dedupe rows older than
+// the retention window.
+type PurgeService struct {
+	repo      *repo.Repo
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewPurgeService(r *repo.Repo, retention, interval time.Duration) *PurgeService {
+	return &PurgeService{
+		repo:      r,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Start purges once straight away, so frequent restarts don't postpone it,
+// and then every interval until ctx is done.
+func (p *PurgeService) Start(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+
+	go func() {
+		p.purge(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			case <-ticker.C:
+				p.purge(ctx)
+			}
+		}
+	}()
+}
+
+func (p *PurgeService) purge(ctx context.Context) {
+	n, err := p.repo.PurgeVisitors(ctx, time.Now().Add(-p.retention))
+	if err != nil {
+		log.Printf("Visitor purge failed: %v", err)
+		return
+	}
+	log.Printf("Purged %d visitor rows older than %s", n, p.retention)
+}
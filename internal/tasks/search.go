@@ -0,0 +1,55 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/thornhall/blog/internal/repo"
+)
+
+// feedItem is the part of a feed.json item the search index needs. The
+// builder puts each post's plain text, without code blocks, in content_text.
+type feedItem struct {
+	Title       string `json:"title"`
+	Summary     string `json:"summary"`
+	ContentText string `json:"content_text"`
+	Blog        struct {
+		Slug string `json:"slug"`
+		Date string `json:"date"`
+	} `json:"_blog"`
+}
+
+// SyncSearchIndex loads the posts from the JSON Feed the builder wrote at
+// feedPath into the full-text search table, replacing what was there.
+// It returns the number of posts indexed.
+func SyncSearchIndex(ctx context.Context, r *repo.Repo, feedPath string) (int, error) {
+	raw, err := os.ReadFile(feedPath)
+	if err != nil {
+		return 0, err
+	}
+
+	var feed struct {
+		Items []feedItem `json:"items"`
+	}
+	if err := json.Unmarshal(raw, &feed); err != nil {
+		return 0, fmt.Errorf("%s: %w", feedPath, err)
+	}
+
+	docs := make([]repo.SearchDoc, 0, len(feed.Items))
+	for _, item := range feed.Items {
+		docs = append(docs, repo.SearchDoc{
+			Slug:    item.Blog.Slug,
+			Date:    item.Blog.Date,
+			Title:   item.Title,
+			Excerpt: item.Summary,
+			Body:    item.ContentText,
+		})
+	}
+
+	if err := r.SyncSearch(ctx, docs); err != nil {
+		return 0, err
+	}
+	return len(docs), nil
+}
@@ -0,0 +1,44 @@
+// Package visitor turns client addresses into identifiers that can be stored
+// without exposing who visited.
+package visitor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Hasher derives visitor IDs as an HMAC-SHA256 of the normalized client
+// address. Without the secret, an ID cannot be traced back to an address,
+// even by hashing every IPv4 address. IDs are only stable while the secret is,
+// so it must not be rotated once IDs have been stored.
+type Hasher struct {
+	secret      []byte
+	rotateDaily bool
+}
+
+// New returns a Hasher keyed with secret. With rotateDaily, the current UTC
+// date is mixed into every hash, so the same address gets a new ID each day
+// and is only deduplicated within that day.
+func New(secret []byte, rotateDaily bool) *Hasher {
+	return &Hasher{
+		secret:      secret,
+		rotateDaily: rotateDaily,
+	}
+}
+
+// ID returns the visitor ID for a normalized address at the current time.
+func (h *Hasher) ID(ip string) string {
+	return h.IDAt(ip, time.Now())
+}
+
+// IDAt returns the visitor ID for a normalized address at time t.
+func (h *Hasher) IDAt(ip string, t time.Time) string {
+	mac := hmac.New(sha256.New, h.secret)
+	if h.rotateDaily {
+		mac.Write([]byte(t.UTC().Format(time.DateOnly) + "|"))
+	}
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,30 @@
+package visitor_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thornhall/blog/internal/visitor"
+)
+
+func TestID(t *testing.T) {
+	day := time.Date(2025, 11, 20, 9, 0, 0, 0, time.UTC)
+	h := visitor.New([]byte("secret"), false)
+
+	id := h.IDAt("203.0.113.7", day)
+	assert.Len(t, id, 64)
+	assert.NotContains(t, id, "203.0.113.7")
+	assert.Equal(t, id, h.IDAt("203.0.113.7", day.AddDate(0, 0, 1)), "IDs are stable without rotation")
+	assert.NotEqual(t, id, h.IDAt("203.0.113.8", day))
+	assert.NotEqual(t, id, visitor.New([]byte("other"), false).IDAt("203.0.113.7", day), "IDs depend on the secret")
+}
+
+func TestIDRotatesDaily(t *testing.T) {
+	day := time.Date(2025, 11, 20, 9, 0, 0, 0, time.UTC)
+	h := visitor.New([]byte("secret"), true)
+
+	id := h.IDAt("203.0.113.7", day)
+	assert.Equal(t, id, h.IDAt("203.0.113.7", day.Add(14*time.Hour)), "IDs are stable within a UTC day")
+	assert.NotEqual(t, id, h.IDAt("203.0.113.7", day.AddDate(0, 0, 1)))
+}